@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+)
+
+// BatchCall is a single line of a batch file.
+type BatchCall struct {
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchResult is written for each BatchCall, in input order. Error is set
+// when the call could not be made at all.
+type BatchResult struct {
+	Index    int             `json:"index"`
+	Method   string          `json:"method"`
+	Status   *ExchangeStatus `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+func (r *BatchResult) failed() bool {
+	return r.Error != "" || r.Status.Code != codes.OK
+}
+
+type BatchCommand struct {
+	cmd      *cobra.Command
+	opts     *GlobalOptions
+	addr     string
+	parallel int
+	invoker  *UnaryInvoker
+}
+
+func NewBatchCommand(opts *GlobalOptions) *BatchCommand {
+	c := &BatchCommand{
+		cmd: &cobra.Command{
+			Use:   "batch ADDR [FILE]",
+			Short: "Call gRPC methods listed in a JSON Lines file",
+			Example: `
+* batch
+echo '{"method": "test.Test.Echo", "headers": {"x-id": "1"}, "body": {"message": "hello"}}' | grpcurl batch localhost:8888
+`,
+			Args:         cobra.RangeArgs(1, 2),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().IntVarP(&c.parallel, "parallel", "P", 1, "number of calls in flight")
+	return c
+}
+
+func (c *BatchCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *BatchCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	input := c.opts.Input
+	if len(args) == 2 && args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+	if c.parallel < 1 {
+		c.parallel = 1
+	}
+
+	c.addr = args[0]
+	conn, err := NewGRPCConnection(ctx, c.addr, c.opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	src, err := NewReflectionSource(ctx, conn, c.addr, c.opts)
+	if err != nil {
+		return err
+	}
+	c.invoker = NewUnaryInvoker(src, conn)
+
+	return c.batch(ctx, input)
+}
+
+func (c *BatchCommand) batch(ctx context.Context, r io.Reader) error {
+	// results are written in input order while up to c.parallel calls are
+	// in flight
+	pending := make(chan chan *BatchResult, c.parallel)
+	sem := make(chan struct{}, c.parallel)
+	var total, failed int
+	var writeErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		enc := json.NewEncoder(c.opts.Output)
+		for ch := range pending {
+			res := <-ch
+			total++
+			if res.failed() {
+				failed++
+			}
+			if err := enc.Encode(res); err != nil && writeErr == nil {
+				writeErr = err
+			}
+		}
+	}()
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var readErr error
+	for i := 0; ; i++ {
+		var call BatchCall
+		err := dec.Decode(&call)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("failed to read call %d: %v", i, err)
+			break
+		}
+
+		ch := make(chan *BatchResult, 1)
+		pending <- ch
+		sem <- struct{}{}
+		go func(i int, call *BatchCall) {
+			defer func() { <-sem }()
+			ch <- c.call(ctx, i, call)
+		}(i, &call)
+	}
+	close(pending)
+	wg.Wait()
+
+	if readErr != nil {
+		return readErr
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d calls failed", failed, total)
+	}
+	return nil
+}
+
+func (c *BatchCommand) call(ctx context.Context, index int, call *BatchCall) *BatchResult {
+	res := &BatchResult{
+		Index:  index,
+		Method: call.Method,
+	}
+
+	md := buildOutgoingMetadata(c.opts.Headers)
+	for k, v := range call.Headers {
+		md.Set(k, v)
+	}
+	out, err := c.invoker.Invoke(ctx, call.Method, md, call.Body)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Status = NewExchangeStatus(out.Status)
+	res.Response = out.Response
+	return res
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBatchCalls = `{"method": "grpcurl.test.Echo.Echo", "body": {"value": "a"}}
+{"method": "grpcurl.test.Echo.Echo", "body": {"value": "b", "error_code": 5}}
+{"method": "grpcurl.test.Echo.Missing"}
+{"method": "grpcurl.test.Everything.Simple", "headers": {"x-test": "1"}, "body": {"string_value": "c"}}
+`
+
+func testBatch(parallel string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(testBatchCalls), buf)
+	cmd.Command().SetArgs([]string{"-k", "batch", "-P", parallel, addr})
+	return buf, cmd.Command().Execute()
+}
+
+func TestBatch(t *testing.T) {
+	for _, parallel := range []string{"1", "4"} {
+		buf, err := testBatch(parallel)
+		assert.EqualError(t, err, "2 of 4 calls failed")
+		expected := `{"index":0,"method":"grpcurl.test.Echo.Echo","status":{"code":0},"response":{"value":"a","error_code":0}}
+{"index":1,"method":"grpcurl.test.Echo.Echo","status":{"code":5,"message":"error msg: b"}}
+{"index":2,"method":"grpcurl.test.Echo.Missing","error":"method couldn't be found"}
+{"index":3,"method":"grpcurl.test.Everything.Simple","status":{"code":0},"response":{"string_value":"c","bool_value":false}}
+`
+		require.Equal(t, expected, buf.String(), "parallel %s", parallel)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// descriptorCache stores the files fetched by server reflection in dir as
+// FileDescriptorSets, one per address, valid for ttl after they are written.
+type descriptorCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func newDescriptorCache(opts *GlobalOptions) (*descriptorCache, error) {
+	dir := opts.CacheDir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find cache directory: %v", err)
+		}
+		dir = filepath.Join(base, "grpcurl", "descriptors")
+	}
+	return &descriptorCache{dir: dir, ttl: opts.CacheTTL}, nil
+}
+
+func (c *descriptorCache) path(addr string) string {
+	return filepath.Join(c.dir, url.QueryEscape(addr)+".protoset")
+}
+
+// Load returns the cached files of addr, or false if there are none or they
+// have expired.
+func (c *descriptorCache) Load(addr string) (*FileDescriptorSource, bool) {
+	info, err := os.Stat(c.path(addr))
+	if err != nil || time.Since(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+	src, err := NewDescriptorSourceFromProtoSets(c.path(addr))
+	if err != nil {
+		return nil, false
+	}
+	return src, true
+}
+
+// Store replaces the cached files of addr.
+func (c *descriptorCache) Store(addr string, files []*desc.FileDescriptor) error {
+	b, err := proto.Marshal(desc.ToFileDescriptorSet(files...))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	// written aside and renamed so that readers never see a partial file
+	tmp, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(addr))
+}
+
+// cachedDescriptorSource is a DescriptorSource answering from the cached
+// files of addr, fetching them by reflection when they are missing, expired
+// or refresh is set. A lookup failing on cached files fetches fresh ones once
+// and retries; the fresh files replace the cached ones only if the retry
+// succeeds, so that looking up a symbol that doesn't exist, such as a typo,
+// leaves the cache alone.
+type cachedDescriptorSource struct {
+	addr   string
+	cache  *descriptorCache
+	remote DescriptorSource
+
+	mu       sync.Mutex
+	refresh  bool
+	fetched  bool
+	src      *FileDescriptorSource
+	unstored []*desc.FileDescriptor
+}
+
+func (s *cachedDescriptorSource) loadLocked() (*FileDescriptorSource, error) {
+	if s.src != nil {
+		return s.src, nil
+	}
+	if !s.refresh {
+		if src, ok := s.cache.Load(s.addr); ok {
+			s.src = src
+			return src, nil
+		}
+	}
+
+	files, err := s.fetchLocked()
+	if err != nil {
+		return nil, err
+	}
+	// a failure to write the cache only costs the next run a fetch
+	s.cache.Store(s.addr, files)
+	return s.src, nil
+}
+
+func (s *cachedDescriptorSource) fetchLocked() ([]*desc.FileDescriptor, error) {
+	files, err := serviceFiles(s.remote, true)
+	if err != nil {
+		return nil, err
+	}
+	s.src = NewFileDescriptorSource(files...)
+	s.fetched = true
+	return files, nil
+}
+
+// refetchLocked replaces cached files not fetched by this source with fresh
+// ones, reporting whether a retry may find what they lacked. The fresh files
+// are stored by storeLocked once the retry succeeds.
+func (s *cachedDescriptorSource) refetchLocked() bool {
+	if s.fetched {
+		return false
+	}
+	files, err := s.fetchLocked()
+	if err != nil {
+		return false
+	}
+	s.unstored = files
+	return true
+}
+
+// storeLocked writes the files fetched by refetchLocked to the cache.
+func (s *cachedDescriptorSource) storeLocked() {
+	if s.unstored == nil {
+		return
+	}
+	s.cache.Store(s.addr, s.unstored)
+	s.unstored = nil
+}
+
+// Refetch fetches fresh files after a failed lookup, as refetchLocked. They
+// are stored as soon as the retried lookup resolves anything.
+func (s *cachedDescriptorSource) Refetch() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refetchLocked()
+}
+
+// ListServices implements DescriptorSource.ListServices
+func (s *cachedDescriptorSource) ListServices() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, err := s.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	return src.ListServices()
+}
+
+// ResolveService implements DescriptorSource.ResolveService
+func (s *cachedDescriptorSource) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		src, err := s.loadLocked()
+		if err != nil {
+			return nil, err
+		}
+		sd, err := src.ResolveService(serviceName)
+		if err != nil && s.refetchLocked() {
+			continue
+		}
+		if err == nil {
+			s.storeLocked()
+		}
+		return sd, err
+	}
+}
+
+// ResolveMessage implements DescriptorSource.ResolveMessage
+func (s *cachedDescriptorSource) ResolveMessage(messageName string) (*desc.MessageDescriptor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		src, err := s.loadLocked()
+		if err != nil {
+			return nil, err
+		}
+		md, err := src.ResolveMessage(messageName)
+		if err == nil {
+			s.storeLocked()
+			return md, nil
+		}
+		if !s.refetchLocked() {
+			// messages of files not reachable from services aren't cached
+			return s.remote.ResolveMessage(messageName)
+		}
+	}
+}
+
+// withoutDescriptorCache returns a copy of o not using the descriptor cache,
+// for commands that must see the current schema of a server, as a cached
+// one may differ without failing any lookup.
+func (o *GlobalOptions) withoutDescriptorCache() *GlobalOptions {
+	uncached := *o
+	uncached.CacheTTL = 0
+	return &uncached
+}
+
+// NewReflectionSource returns a DescriptorSource resolving by server
+// reflection over conn, through the descriptor cache of addr when
+// opts.CacheTTL is set.
+func NewReflectionSource(ctx context.Context, conn *grpc.ClientConn, addr string, opts *GlobalOptions) (DescriptorSource, error) {
+	remote := NewServerReflectionClient(ctx, conn, opts)
+	if opts.CacheTTL <= 0 {
+		return remote, nil
+	}
+	cache, err := newDescriptorCache(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedDescriptorSource{
+		addr:    addr,
+		cache:   cache,
+		remote:  remote,
+		refresh: opts.RefreshCache,
+	}, nil
+}
+
+// refetchDescriptorCache fetches fresh files for src, if it is cached,
+// reporting whether a lookup should be retried.
+func refetchDescriptorCache(src DescriptorSource) bool {
+	s, ok := src.(*cachedDescriptorSource)
+	return ok && s.Refetch()
+}
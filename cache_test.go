@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	test "github.com/kazegusuri/grpcurl/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startCacheTestServer(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := test.NewServer()
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String(), s.Stop
+}
+
+// staleProtoDir returns a directory with stale.proto, an Echo service with
+// only its Echo method.
+func staleProtoDir(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stale.proto"), []byte(`syntax = "proto3";
+package grpcurl.test;
+message EchoMessage {
+  string value = 1;
+  uint32 error_code = 2;
+}
+service Echo {
+  rpc Echo(EchoMessage) returns (EchoMessage) {}
+}
+`), 0644))
+	return dir
+}
+
+// storeStaleCache caches for addr the Echo service of staleProtoDir.
+func storeStaleCache(t *testing.T, cacheDir, addr string) {
+	src, err := NewDescriptorSourceFromProtoFiles([]string{staleProtoDir(t)}, "stale.proto")
+	require.NoError(t, err)
+	cache := &descriptorCache{dir: cacheDir, ttl: time.Hour}
+	require.NoError(t, cache.Store(addr, src.Files()))
+}
+
+func testCached(t *testing.T, cacheDir, input string, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(input), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "--cache-ttl", "1h", "--cache-dir", cacheDir}, args...))
+	err := cmd.Command().Execute()
+	return buf.String(), err
+}
+
+func TestDescriptorCache(t *testing.T) {
+	cacheDir := t.TempDir()
+	server, stop := startCacheTestServer(t)
+
+	out, err := testCached(t, cacheDir, "", "list_services", server, "grpcurl.test.v2.Echo")
+	require.NoError(t, err)
+	assert.Equal(t, "grpcurl.test.v2.Echo.Echo\n", out)
+	assert.FileExists(t, (&descriptorCache{dir: cacheDir}).path(server))
+
+	// answered from the cache without the server
+	stop()
+	out, err = testCached(t, cacheDir, "", "list_services", server, "grpcurl.test.v2.Echo")
+	require.NoError(t, err)
+	assert.Equal(t, "grpcurl.test.v2.Echo.Echo\n", out)
+}
+
+func TestDescriptorCacheOffByDefault(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	server, _ := startCacheTestServer(t)
+	cache, err := newDescriptorCache(&GlobalOptions{})
+	require.NoError(t, err)
+	storeStaleCache(t, cache.dir, server)
+	path := cache.path(server)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "list_services", server, "grpcurl.test.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Contains(t, buf.String(), "grpcurl.test.Echo.ServerStreamingEcho\n")
+
+	// neither read nor written
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime(), after.ModTime())
+}
+
+func TestDescriptorCacheBypassedByCompat(t *testing.T) {
+	cacheDir := t.TempDir()
+	server, _ := startCacheTestServer(t)
+	storeStaleCache(t, cacheDir, server)
+
+	// the server has methods missing in the stale schema
+	out, err := testCached(t, cacheDir, "", "compat", server, staleProtoDir(t))
+	assert.Error(t, err)
+	assert.Contains(t, out, "ServerStreamingEcho")
+}
+
+func TestDescriptorCacheExpired(t *testing.T) {
+	cacheDir := t.TempDir()
+	server, _ := startCacheTestServer(t)
+	storeStaleCache(t, cacheDir, server)
+	path := (&descriptorCache{dir: cacheDir}).path(server)
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	out, err := testCached(t, cacheDir, "", "list_services", server, "grpcurl.test.Echo")
+	require.NoError(t, err)
+	assert.Contains(t, out, "grpcurl.test.Echo.ServerStreamingEcho\n")
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().After(old))
+}
+
+func TestDescriptorCacheRefresh(t *testing.T) {
+	cacheDir := t.TempDir()
+	server, _ := startCacheTestServer(t)
+	storeStaleCache(t, cacheDir, server)
+
+	out, err := testCached(t, cacheDir, "", "list_services", server, "grpcurl.test.Echo")
+	require.NoError(t, err)
+	assert.Equal(t, "grpcurl.test.Echo.Echo\n", out)
+
+	out, err = testCached(t, cacheDir, "", "--refresh", "list_services", server, "grpcurl.test.Echo")
+	require.NoError(t, err)
+	assert.Contains(t, out, "grpcurl.test.Echo.ServerStreamingEcho\n")
+}
+
+func TestDescriptorCacheInvalidatedByMethodLookup(t *testing.T) {
+	cacheDir := t.TempDir()
+	server, _ := startCacheTestServer(t)
+	storeStaleCache(t, cacheDir, server)
+
+	out, err := testCached(t, cacheDir, `{"value": "hello"}`, "call", server, "grpcurl.test.Echo.ServerStreamingEcho")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"value":"hello"`)
+
+	// the cache now has the whole service
+	out, err = testCached(t, cacheDir, "", "list_services", server, "grpcurl.test.Echo")
+	require.NoError(t, err)
+	assert.Contains(t, out, "grpcurl.test.Echo.ServerStreamingEcho\n")
+}
+
+func TestDescriptorCacheKeptOnUnknownSymbol(t *testing.T) {
+	cacheDir := t.TempDir()
+	server, _ := startCacheTestServer(t)
+	storeStaleCache(t, cacheDir, server)
+
+	_, err := testCached(t, cacheDir, `{}`, "call", server, "grpcurl.test.Ehco.Echo")
+	assert.Error(t, err)
+
+	// the cache is still the stale one
+	out, err := testCached(t, cacheDir, "", "list_services", server, "grpcurl.test.Echo")
+	require.NoError(t, err)
+	assert.Equal(t, "grpcurl.test.Echo.Echo\n", out)
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/dynamic"
-	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
-	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
@@ -25,6 +28,7 @@ const (
 	responseMessageMarker = "<== Response Message"
 	responseHeaderMarker  = "<== Response Headers"
 	responseTrailerMarker = "<== Response Trailer"
+	responseStatusMarker  = "<== Response Status"
 )
 
 type CallCommand struct {
@@ -32,10 +36,30 @@ type CallCommand struct {
 	opts        *GlobalOptions
 	headers     []string
 	addr        string
-	rcli        *grpcreflect.Client
-	stub        grpcdynamic.Stub
+	protocol    string
+	codec       string
+	viaHTTP     string
+	sourceOpts  DescriptorSourceOptions
+	source      DescriptorSource
+	transport   CallTransport
 	marshaler   *jsonpb.Marshaler
 	unmarshaler *jsonpb.Unmarshaler
+	recordFile  string
+	recorder    *ExchangeRecorder
+	vars        []string
+	varFiles    []string
+	useTemplate bool
+	template    *RequestTemplate
+	data        []string
+	selectPath  string
+	fields      string
+	filter      *ResponseFilter
+	style       *outputStyle
+	writeOut    string
+	timer       *callTimer
+	tracer      *tracer
+	trace       bool
+	traceFile   string
 }
 
 func NewCallCommand(opts *GlobalOptions) *CallCommand {
@@ -46,6 +70,19 @@ func NewCallCommand(opts *GlobalOptions) *CallCommand {
 			Example: `
 * call
 echo '{"message": "hello"}' | grpcurl call localhost:8888 test.Test.Echo
+echo '{"message": "hello"}' | grpcurl call --protocol grpc-web --proto test.proto https://envoy.example.com test.Test.Echo
+echo '{"message": "hello"}' | grpcurl call --protocol connect --codec json --proto test.proto https://api.example.com test.Test.Echo
+echo '{"message": "hello"}' | grpcurl call --via-http http://localhost:8080 localhost:8888 test.Test.Echo
+
+* call with fields set from the command line
+grpcurl call -d message=hello -d 'labels[env]=dev' -d 'items[].id=1' localhost:8888 test.Test.Echo
+
+* print parts of the responses
+echo '{}' | grpcurl call --select '$.items[*].id' localhost:8888 test.Test.List
+echo '{}' | grpcurl call --fields items.id,next_page_token localhost:8888 test.Test.List
+
+* print timings
+echo '{}' | grpcurl call -w 'connect: %{time_connect} first byte: %{time_starttransfer} total: %{time_total}\n' localhost:8888 test.Test.Echo
 `,
 			Args:         cobra.ExactArgs(2),
 			SilenceUsage: true,
@@ -53,7 +90,22 @@ echo '{"message": "hello"}' | grpcurl call localhost:8888 test.Test.Echo
 		opts: opts,
 	}
 	c.cmd.RunE = c.Run
+	c.cmd.ValidArgsFunction = c.complete
 	c.cmd.Flags().StringArrayVarP(&c.headers, "header", "H", nil, "header")
+	c.cmd.Flags().StringVar(&c.recordFile, "record", "", "append the exchange to FILE as JSON Lines")
+	c.cmd.Flags().StringVar(&c.protocol, "protocol", protocolGRPC, "protocol to call with: grpc, grpc-web or connect")
+	c.cmd.Flags().StringVar(&c.codec, "codec", connectCodecProto, "message encoding of the connect protocol: proto or json")
+	c.cmd.Flags().StringVar(&c.viaHTTP, "via-http", "", "send the call to the HTTP/JSON gateway at BASEURL, following the method's google.api.http rule")
+	c.cmd.Flags().BoolVar(&c.useTemplate, "template", false, "expand the request, -H and -d values as templates (implied by --var and --var-file)")
+	c.cmd.Flags().StringArrayVar(&c.vars, "var", nil, "variable of the request template as name=value")
+	c.cmd.Flags().StringArrayVar(&c.varFiles, "var-file", nil, "YAML or JSON file of variables of the request template")
+	c.cmd.Flags().StringArrayVarP(&c.data, "data", "d", nil, "field of the request as field.path=value, set over the JSON input")
+	c.cmd.Flags().StringVar(&c.selectPath, "select", "", "JSON path of the values of each response to print, such as $.items[*].id")
+	c.cmd.Flags().StringVar(&c.fields, "fields", "", "comma-separated field paths of each response to print, such as a.b,c")
+	c.cmd.Flags().BoolVar(&c.trace, "trace", false, "print every event of the connections and RPCs with the time since the start to stderr")
+	c.cmd.Flags().StringVar(&c.traceFile, "trace-file", "", "write the events of --trace to FILE instead of stderr (implies --trace)")
+	c.cmd.Flags().StringVarP(&c.writeOut, "write-out", "w", "", "format of timings and sizes to print after the call, such as %{time_total}\\n, or @FILE to read it from")
+	c.sourceOpts.AddFlags(c.cmd.Flags())
 	return c
 }
 
@@ -65,26 +117,186 @@ func (c *CallCommand) Run(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
 	c.addr = args[0]
-	conn, err := NewGRPCConnection(ctx, c.addr, c.opts.Insecure)
+	var hooks dialHooks
+	var statsHandlers multiStatsHandler
+	if c.writeOut != "" {
+		if c.protocol != protocolGRPC || c.viaHTTP != "" {
+			return fmt.Errorf("--write-out can only be used with --protocol %s", protocolGRPC)
+		}
+		format, err := parseWriteOut(c.writeOut)
+		if err != nil {
+			return err
+		}
+		c.writeOut = format
+		c.timer = newCallTimer()
+		hooks.Add(c.timer)
+		statsHandlers = append(statsHandlers, c.timer)
+	}
+	if c.trace || c.traceFile != "" {
+		if c.protocol != protocolGRPC || c.viaHTTP != "" {
+			return fmt.Errorf("--trace can only be used with --protocol %s", protocolGRPC)
+		}
+		// events go apart from the output, which stays parsable
+		w := cmd.ErrOrStderr()
+		if c.traceFile != "" {
+			f, err := os.Create(c.traceFile)
+			if err != nil {
+				return fmt.Errorf("failed to create trace file: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		c.tracer = newTracer(w)
+		defer c.tracer.Close()
+		hooks.Add(c.tracer)
+		statsHandlers = append(statsHandlers, c.tracer)
+	}
+	dialOpts, err := hooks.DialOptions(c.opts)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-	c.rcli = NewServerReflectionClient(ctx, conn)
-	c.stub = grpcdynamic.NewStub(conn)
-	c.marshaler = &jsonpb.Marshaler{
+	if len(statsHandlers) > 0 {
+		dialOpts = append(dialOpts, grpc.WithStatsHandler(statsHandlers))
+	}
+	switch c.protocol {
+	case protocolGRPC:
+		conn, err := NewGRPCConnection(ctx, c.addr, c.opts, dialOpts...)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if c.tracer != nil {
+			watchCtx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			c.tracer.WatchState(watchCtx, conn)
+		}
+		c.source, err = NewReflectionSource(ctx, conn, c.addr, c.opts)
+		if err != nil {
+			return err
+		}
+		c.transport = NewGRPCTransport(conn)
+		if c.viaHTTP != "" {
+			client, err := c.opts.HTTPClient()
+			if err != nil {
+				return err
+			}
+			c.transport = NewHTTPTransport(client, c.viaHTTP)
+		}
+	case protocolGRPCWeb, protocolConnect:
+		if c.viaHTTP != "" {
+			return fmt.Errorf("--via-http cannot be used with --protocol %s", c.protocol)
+		}
+		// server reflection is a bidi streaming method
+		if !c.sourceOpts.IsSet() {
+			return fmt.Errorf("--proto or --protoset is required with --protocol %s", c.protocol)
+		}
+		client, err := c.opts.HTTP1Client()
+		if err != nil {
+			return err
+		}
+		if c.protocol == protocolGRPCWeb {
+			c.transport = NewGRPCWebTransport(client, c.addr, c.opts.Insecure)
+			break
+		}
+		transport, err := NewConnectTransport(client, c.addr, c.opts.Insecure, c.codec)
+		if err != nil {
+			return err
+		}
+		c.transport = transport
+	default:
+		return fmt.Errorf("unknown protocol: %s", c.protocol)
+	}
+	if c.sourceOpts.IsSet() {
+		src, err := c.sourceOpts.Load()
+		if err != nil {
+			return err
+		}
+		c.source = src
+	}
+	c.marshaler = newJSONMarshaler()
+	c.unmarshaler = newJSONUnmarshaler()
+	if c.useTemplate || len(c.vars) > 0 || len(c.varFiles) > 0 {
+		tmpl, err := NewRequestTemplate(c.varFiles, c.vars)
+		if err != nil {
+			return err
+		}
+		c.template = tmpl
+	}
+	filter, err := NewResponseFilter(c.fields, c.selectPath)
+	if err != nil {
+		return err
+	}
+	c.filter = filter
+	style, err := newOutputStyle(c.opts.Color, c.opts.Output)
+	if err != nil {
+		return err
+	}
+	c.style = style
+	if c.recordFile != "" {
+		f, err := os.OpenFile(c.recordFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open record file: %v", err)
+		}
+		defer f.Close()
+		c.recorder = NewExchangeRecorder(f)
+	}
+
+	if err := c.call(ctx, args[1], c.opts.Input); err != nil {
+		return err
+	}
+	recordAddress(c.addr)
+	if c.timer != nil {
+		fmt.Fprint(c.opts.Output, expandWriteOut(c.writeOut, c.timer.Variables()))
+	}
+	return nil
+}
+
+func (c *CallCommand) complete(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeAddress(toComplete)
+	case 1:
+		if c.protocol != protocolGRPC {
+			// server reflection needs gRPC
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeMethod(c.opts, args[0], toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func newJSONMarshaler() *jsonpb.Marshaler {
+	return &jsonpb.Marshaler{
 		OrigName:     true,
 		EmitDefaults: true,
 		AnyResolver:  DynamicAnyResolver{},
 	}
-	c.unmarshaler = &jsonpb.Unmarshaler{
+}
+
+func newJSONUnmarshaler() *jsonpb.Unmarshaler {
+	return &jsonpb.Unmarshaler{
 		AllowUnknownFields: true,
 	}
+}
 
-	if err := c.call(ctx, args[1], c.opts.Input); err != nil {
-		return err
+// mergeHeaders returns the default headers, except those with the key of one
+// of headers, followed by headers.
+func mergeHeaders(defaults, headers []string) []string {
+	keys := map[string]bool{}
+	for _, h := range headers {
+		keys[headerKey(h)] = true
 	}
-	return nil
+	var merged []string
+	for _, h := range defaults {
+		if !keys[headerKey(h)] {
+			merged = append(merged, h)
+		}
+	}
+	return append(merged, headers...)
+}
+
+func headerKey(header string) string {
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(header, ":", 2)[0]))
 }
 
 func buildOutgoingMetadata(header []string) metadata.MD {
@@ -103,6 +315,10 @@ func buildOutgoingMetadata(header []string) metadata.MD {
 }
 
 func (c CallCommand) resolveMessage(fullMethodName string) (*desc.MethodDescriptor, error) {
+	return resolveMethod(c.source, fullMethodName)
+}
+
+func resolveMethod(src DescriptorSource, fullMethodName string) (*desc.MethodDescriptor, error) {
 	// assume that fully-qualified method name cosists of
 	// FULL_SERVER_NAME + "." + METHOD_NAME
 	// so split the last dot to get service name
@@ -113,13 +329,17 @@ func (c CallCommand) resolveMessage(fullMethodName string) (*desc.MethodDescript
 	serviceName := fullMethodName[0:n]
 	methodName := fullMethodName[n+1:]
 
-	sdesc, err := c.rcli.ResolveService(serviceName)
+	sdesc, err := src.ResolveService(serviceName)
 	if err != nil {
 		return nil, fmt.Errorf("service couldn't be resolve: %v: %v", err, serviceName)
 	}
 
 	mdesc := sdesc.FindMethodByName(methodName)
 	if mdesc == nil {
+		// the cached descriptors may predate the method
+		if refetchDescriptorCache(src) {
+			return resolveMethod(src, fullMethodName)
+		}
 		return nil, fmt.Errorf("method couldn't be found")
 	}
 
@@ -128,72 +348,160 @@ func (c CallCommand) resolveMessage(fullMethodName string) (*desc.MethodDescript
 
 func (c CallCommand) createMessage(mdesc *desc.MethodDescriptor, r io.Reader) (*dynamic.Message, error) {
 	msg := dynamic.NewMessage(mdesc.GetInputType())
+	data := c.data
+	if c.template != nil {
+		data = make([]string, len(c.data))
+		for i, d := range c.data {
+			var err error
+			if data[i], err = c.template.Expand(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(data) > 0 && isTerminal(r) {
+		// the fields are given by -d alone
+		return msg, setFieldPaths(msg, data, c.unmarshaler)
+	}
 	input, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to ReadAll %v", err)
 	}
+	if len(data) > 0 && len(bytes.TrimSpace(input)) == 0 {
+		input = []byte("{}")
+	}
+	if c.template != nil {
+		s, err := c.template.ExpandJSON(string(input))
+		if err != nil {
+			return nil, err
+		}
+		input = []byte(s)
+	}
 	if err = msg.UnmarshalJSONPB(c.unmarshaler, input); err != nil {
 		return nil, fmt.Errorf("unmarshal %v", err)
 	}
+	if err := setFieldPaths(msg, data, c.unmarshaler); err != nil {
+		return nil, err
+	}
 	return msg, nil
 }
 
+// isTerminal reports whether v is a file of a terminal.
+func isTerminal(v interface{}) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func (c CallCommand) call(ctx context.Context, fullMethodName string, reader io.Reader) error {
 	mdesc, err := c.resolveMessage(fullMethodName)
 	if err != nil {
 		return err
 	}
+	if c.timer != nil {
+		c.timer.Resolved(mdesc)
+	}
 
-	ctx = metadata.NewOutgoingContext(ctx, buildOutgoingMetadata(c.headers))
+	headers := c.headers
+	if c.template != nil {
+		headers = make([]string, len(c.headers))
+		for i, h := range c.headers {
+			if headers[i], err = c.template.Expand(h); err != nil {
+				return err
+			}
+		}
+	}
+	md := buildOutgoingMetadata(mergeHeaders(c.opts.Headers, headers))
+	ctx = metadata.NewOutgoingContext(ctx, md)
 
 	msg, err := c.createMessage(mdesc, reader)
 	if err != nil {
 		return err
 	}
 
+	reqJSON, err := msg.MarshalJSONPB(c.marshaler)
+	if err != nil {
+		return fmt.Errorf("marshal %v", err)
+	}
 	if c.opts.Verbose {
-		reqJSON, err := msg.MarshalJSONPB(c.marshaler)
+		fmt.Fprintln(c.opts.Output, c.style.Marker(requestMessageMarker))
+		fmt.Fprintf(c.opts.Output, "%s\n", c.style.JSON(string(reqJSON)))
+	}
+
+	var responses []json.RawMessage
+	headerMD, trailerMD, err := c.transport.Invoke(ctx, mdesc, msg, func(resp proto.Message) error {
+		respJSON, err := c.marshaler.MarshalToString(resp)
 		if err != nil {
 			return fmt.Errorf("marshal %v", err)
 		}
-		fmt.Fprintln(c.opts.Output, requestMessageMarker)
-		fmt.Fprintf(c.opts.Output, "%s\n", string(reqJSON))
-	}
-
-	var headerMD metadata.MD
-	var trailerMD metadata.MD
-	resp, err := c.stub.InvokeRpc(ctx, mdesc, msg, grpc.Header(&headerMD), grpc.Trailer(&trailerMD))
+		lines := []string{respJSON}
+		if c.filter != nil {
+			if lines, err = c.filter.Apply(respJSON); err != nil {
+				return err
+			}
+		}
+		if c.opts.Verbose {
+			fmt.Fprintln(c.opts.Output, c.style.Marker(responseMessageMarker))
+		}
+		for _, line := range lines {
+			fmt.Fprintf(c.opts.Output, "%s\n", c.style.JSON(line))
+		}
+		responses = append(responses, json.RawMessage(respJSON))
+		return nil
+	})
+	st := status.New(codes.OK, "")
+	defer func() {
+		if c.timer != nil {
+			c.timer.Done(st.Code())
+		}
+	}()
 	if err != nil {
-		st, ok := status.FromError(err)
+		var ok bool
+		st, ok = status.FromError(err)
 		if !ok {
-			return fmt.Errorf("unknown error: %v", err)
+			return err
 		}
 
-		resp = st.Proto()
+		respJSON, err := c.marshaler.MarshalToString(st.Proto())
+		if err != nil {
+			return fmt.Errorf("marshal %v", err)
+		}
+		if c.opts.Verbose {
+			fmt.Fprintln(c.opts.Output, c.style.Marker(responseMessageMarker))
+		}
+		fmt.Fprintf(c.opts.Output, "%s\n", c.style.JSON(respJSON))
 	}
 
-	respJSON, err := c.marshaler.MarshalToString(resp)
-	if err != nil {
-		return fmt.Errorf("marshal %v", err)
+	if c.recorder != nil {
+		// responses received before an error status are recorded as well,
+		// so that replay compares partial streams
+		e := &Exchange{
+			Method:    fullMethodName,
+			Metadata:  md,
+			Request:   reqJSON,
+			Responses: responses,
+			Headers:   headerMD,
+			Trailers:  trailerMD,
+			Status:    NewExchangeStatus(st),
+		}
+		if err := c.recorder.Record(e); err != nil {
+			return err
+		}
 	}
 
 	if c.opts.Verbose {
-		fmt.Fprintln(c.opts.Output, responseMessageMarker)
-	}
-	fmt.Fprintf(c.opts.Output, "%s\n", respJSON)
-	if c.opts.Verbose {
-		fmt.Fprintln(c.opts.Output, responseHeaderMarker)
-		for k, vs := range headerMD {
-			for i := range vs {
-				fmt.Fprintf(c.opts.Output, "%s: %s\n", k, vs[i])
-			}
-		}
+		fmt.Fprintln(c.opts.Output, c.style.Marker(responseHeaderMarker))
+		c.style.WriteMetadata(c.opts.Output, headerMD)
 
-		fmt.Fprintln(c.opts.Output, responseTrailerMarker)
-		for k, vs := range trailerMD {
-			for i := range vs {
-				fmt.Fprintf(c.opts.Output, "%s: %s\n", k, vs[i])
-			}
+		fmt.Fprintln(c.opts.Output, c.style.Marker(responseTrailerMarker))
+		c.style.WriteMetadata(c.opts.Output, trailerMD)
+
+		// compact output keeps the markers scripts parse
+		if c.style.pretty {
+			fmt.Fprintln(c.opts.Output, c.style.Marker(responseStatusMarker))
+			fmt.Fprintln(c.opts.Output, c.style.Status(st.Code()))
 		}
 	}
 
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// outputStyle is how messages and metadata are rendered. Output to a
+// terminal, or colored output, is pretty: indented JSON and aligned
+// metadata. Otherwise output is compact, as scripts expect.
+type outputStyle struct {
+	color  bool
+	pretty bool
+}
+
+// newOutputStyle returns the style of output to w for a --color mode. In
+// auto mode, output is colored on terminals unless NO_COLOR is set.
+func newOutputStyle(mode string, w io.Writer) (*outputStyle, error) {
+	tty := isTerminal(w)
+	s := &outputStyle{pretty: tty}
+	switch mode {
+	case colorAuto:
+		s.color = tty && !noColor()
+	case colorAlways:
+		s.color, s.pretty = true, true
+	case colorNever:
+	default:
+		return nil, fmt.Errorf("unknown color mode: %s (must be auto, always or never)", mode)
+	}
+	return s, nil
+}
+
+// noColor reports whether NO_COLOR asks to disable colors, which an empty
+// value doesn't (https://no-color.org).
+func noColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+func (s *outputStyle) paint(code, text string) string {
+	if !s.color {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// Marker renders a verbose marker.
+func (s *outputStyle) Marker(marker string) string {
+	return s.paint(ansiBold+ansiCyan, marker)
+}
+
+// Status renders a status code, green if OK and red otherwise.
+func (s *outputStyle) Status(code codes.Code) string {
+	if code == codes.OK {
+		return s.paint(ansiGreen, code.String())
+	}
+	return s.paint(ansiRed, code.String())
+}
+
+// JSON renders a JSON value, indented and colored if the style is pretty.
+// Text that is not JSON is returned as it is.
+func (s *outputStyle) JSON(js string) string {
+	if !s.pretty || !json.Valid([]byte(js)) {
+		return js
+	}
+	buf := &bytes.Buffer{}
+	json.Indent(buf, []byte(js), "", "  ")
+	if !s.color {
+		return buf.String()
+	}
+	return colorizeJSON(buf.String())
+}
+
+// WriteMetadata writes md one entry per line, sorted by key. Pretty
+// output aligns the values in a dimmed table.
+func (s *outputStyle) WriteMetadata(w io.Writer, md metadata.MD) {
+	keys := make([]string, 0, len(md))
+	width := 0
+	for k := range md {
+		keys = append(keys, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range md[k] {
+			if !s.pretty {
+				fmt.Fprintf(w, "%s: %s\n", k, v)
+				continue
+			}
+			fmt.Fprintln(w, s.paint(ansiDim, fmt.Sprintf("%-*s  %s", width+1, k+":", v)))
+		}
+	}
+}
+
+// colorizeJSON colors the keys, strings, numbers and literals of js, which
+// must be valid JSON.
+func colorizeJSON(js string) string {
+	var b strings.Builder
+	for i := 0; i < len(js); {
+		c := js[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for ; j < len(js) && js[j] != '"'; j++ {
+				if js[j] == '\\' {
+					j++
+				}
+			}
+			j++
+			code := ansiGreen
+			if k := strings.TrimLeft(js[j:], " \t\r\n"); strings.HasPrefix(k, ":") {
+				code = ansiBlue
+			}
+			b.WriteString(code + js[i:j] + ansiReset)
+			i = j
+		case c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z':
+			j := i
+			for ; j < len(js) && strings.IndexByte("+-.0123456789abcdefghijklmnopqrstuvwxyzE", js[j]) >= 0; j++ {
+			}
+			code := ansiYellow
+			if c >= 'a' && c <= 'z' {
+				code = ansiMagenta
+			}
+			b.WriteString(code + js[i:j] + ansiReset)
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func testColorCall(t *testing.T, body string, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(body), buf)
+	cmd.Command().SetArgs(append([]string{"-k"}, append(args, "call", addr, "grpcurl.test.Echo.Echo")...))
+	err := cmd.Command().Execute()
+	return buf.String(), err
+}
+
+func TestColorAlways(t *testing.T) {
+	out, err := testColorCall(t, `{"value": "hi"}`, "--color", "always", "-v")
+	require.NoError(t, err)
+	assert.Contains(t, out, ansiBold+ansiCyan+responseMessageMarker+ansiReset+"\n")
+	assert.Contains(t, out, "{\n  "+ansiBlue+`"value"`+ansiReset+": "+ansiGreen+`"hi"`+ansiReset+",\n")
+	assert.Contains(t, out, ansiBlue+`"error_code"`+ansiReset+": "+ansiYellow+"0"+ansiReset+"\n}")
+	assert.True(t, strings.HasSuffix(out, ansiBold+ansiCyan+responseStatusMarker+ansiReset+"\n"+ansiGreen+"OK"+ansiReset+"\n"), out)
+
+	out, err = testColorCall(t, `{"value": "hi", "error_code": 5}`, "--color", "always", "-v")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(out, ansiRed+"NotFound"+ansiReset+"\n"), out)
+}
+
+func TestColorCompact(t *testing.T) {
+	for _, mode := range []string{colorAuto, colorNever} {
+		out, err := testColorCall(t, `{"value": "hi"}`, "--color", mode)
+		require.NoError(t, err)
+		assert.Equal(t, `{"value":"hi","error_code":0}`+"\n", out)
+	}
+
+	_, err := testColorCall(t, `{}`, "--color", "sometimes")
+	assert.EqualError(t, err, "unknown color mode: sometimes (must be auto, always or never)")
+}
+
+func TestNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	assert.False(t, noColor())
+	t.Setenv("NO_COLOR", "1")
+	assert.True(t, noColor())
+}
+
+func TestColorizeJSON(t *testing.T) {
+	assert.Equal(t,
+		`[`+ansiGreen+`"a\"b"`+ansiReset+`, `+ansiYellow+`-1.5e+3`+ansiReset+`, `+ansiMagenta+`true`+ansiReset+`, `+ansiMagenta+`null`+ansiReset+`]`,
+		colorizeJSON(`["a\"b", -1.5e+3, true, null]`))
+}
+
+func TestWriteMetadata(t *testing.T) {
+	md := metadata.Pairs("b", "2", "content-type", "application/grpc", "b", "3")
+
+	buf := &bytes.Buffer{}
+	(&outputStyle{}).WriteMetadata(buf, md)
+	assert.Equal(t, "b: 2\nb: 3\ncontent-type: application/grpc\n", buf.String())
+
+	buf.Reset()
+	(&outputStyle{pretty: true}).WriteMetadata(buf, md)
+	assert.Equal(t, "b:             2\nb:             3\ncontent-type:  application/grpc\n", buf.String())
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/spf13/cobra"
+)
+
+// compatChange is a change between two schemas that breaks clients using
+// the binary wire format, the JSON mapping, or both.
+type compatChange struct {
+	Wire    bool
+	JSON    bool
+	Subject string
+	Message string
+}
+
+func (c compatChange) String() string {
+	var kinds []string
+	if c.Wire {
+		kinds = append(kinds, "wire")
+	}
+	if c.JSON {
+		kinds = append(kinds, "json")
+	}
+	return fmt.Sprintf("[%s] %s: %s", strings.Join(kinds, ","), c.Subject, c.Message)
+}
+
+// wireCompatibleTypes groups scalar types sharing an encoding on the wire.
+// Changing a field between types of a group keeps binary compatibility but
+// still changes its JSON representation.
+var wireCompatibleTypes = map[dpb.FieldDescriptorProto_Type]int{
+	dpb.FieldDescriptorProto_TYPE_INT32:    1,
+	dpb.FieldDescriptorProto_TYPE_INT64:    1,
+	dpb.FieldDescriptorProto_TYPE_UINT32:   1,
+	dpb.FieldDescriptorProto_TYPE_UINT64:   1,
+	dpb.FieldDescriptorProto_TYPE_BOOL:     1,
+	dpb.FieldDescriptorProto_TYPE_ENUM:     1,
+	dpb.FieldDescriptorProto_TYPE_SINT32:   2,
+	dpb.FieldDescriptorProto_TYPE_SINT64:   2,
+	dpb.FieldDescriptorProto_TYPE_FIXED32:  3,
+	dpb.FieldDescriptorProto_TYPE_SFIXED32: 3,
+	dpb.FieldDescriptorProto_TYPE_FIXED64:  4,
+	dpb.FieldDescriptorProto_TYPE_SFIXED64: 4,
+	dpb.FieldDescriptorProto_TYPE_STRING:   5,
+	dpb.FieldDescriptorProto_TYPE_BYTES:    5,
+}
+
+// compatChecker compares the services of an old schema with a new one,
+// following the messages and enums reachable from their methods.
+type compatChecker struct {
+	changes []compatChange
+	visited map[string]bool
+}
+
+func (c *compatChecker) add(wire, json bool, subject, format string, args ...interface{}) {
+	c.changes = append(c.changes, compatChange{
+		Wire:    wire,
+		JSON:    json,
+		Subject: subject,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *compatChecker) checkSources(oldSrc, newSrc DescriptorSource) error {
+	svcs, err := oldSrc.ListServices()
+	if err != nil {
+		return fmt.Errorf("failed to list old services: %v", err)
+	}
+	newSvcs, err := newSrc.ListServices()
+	if err != nil {
+		return fmt.Errorf("failed to list new services: %v", err)
+	}
+	exists := map[string]bool{}
+	for _, name := range newSvcs {
+		exists[name] = true
+	}
+
+	sort.Strings(svcs)
+	for _, name := range svcs {
+		if strings.HasPrefix(name, "grpc.reflection.") {
+			continue
+		}
+		if !exists[name] {
+			c.add(true, true, name, "service removed")
+			continue
+		}
+		oldSvc, err := oldSrc.ResolveService(name)
+		if err != nil {
+			return err
+		}
+		newSvc, err := newSrc.ResolveService(name)
+		if err != nil {
+			return err
+		}
+		c.checkService(oldSvc, newSvc)
+	}
+	return nil
+}
+
+func (c *compatChecker) checkService(oldSvc, newSvc *desc.ServiceDescriptor) {
+	for _, oldMethod := range oldSvc.GetMethods() {
+		name := oldMethod.GetFullyQualifiedName()
+		newMethod := newSvc.FindMethodByName(oldMethod.GetName())
+		if newMethod == nil {
+			c.add(true, true, name, "method removed")
+			continue
+		}
+		if oldMethod.IsClientStreaming() != newMethod.IsClientStreaming() ||
+			oldMethod.IsServerStreaming() != newMethod.IsServerStreaming() {
+			c.add(true, true, name, "streaming mode changed from %s to %s",
+				streamingMode(oldMethod), streamingMode(newMethod))
+		}
+		// renaming a message type is compatible as long as its fields are
+		c.checkMessage(oldMethod.GetInputType(), newMethod.GetInputType())
+		c.checkMessage(oldMethod.GetOutputType(), newMethod.GetOutputType())
+	}
+}
+
+func streamingMode(mdesc *desc.MethodDescriptor) string {
+	switch {
+	case mdesc.IsClientStreaming() && mdesc.IsServerStreaming():
+		return "bidi streaming"
+	case mdesc.IsClientStreaming():
+		return "client streaming"
+	case mdesc.IsServerStreaming():
+		return "server streaming"
+	default:
+		return "unary"
+	}
+}
+
+func (c *compatChecker) checkMessage(oldMsg, newMsg *desc.MessageDescriptor) {
+	key := oldMsg.GetFullyQualifiedName() + " " + newMsg.GetFullyQualifiedName()
+	if c.visited[key] {
+		return
+	}
+	c.visited[key] = true
+
+	for _, oldField := range oldMsg.GetFields() {
+		subject := oldField.GetFullyQualifiedName()
+		newField := newMsg.FindFieldByNumber(oldField.GetNumber())
+		if newField == nil {
+			c.add(true, true, subject, "field %d removed", oldField.GetNumber())
+			continue
+		}
+		if oldField.GetName() != newField.GetName() {
+			c.add(false, true, subject, "field %d renamed to %s", oldField.GetNumber(), newField.GetName())
+		} else if oldField.GetJSONName() != newField.GetJSONName() {
+			c.add(false, true, subject, "JSON name changed from %s to %s", oldField.GetJSONName(), newField.GetJSONName())
+		}
+		if oldField.IsRepeated() != newField.IsRepeated() || oldField.IsMap() != newField.IsMap() {
+			c.add(true, true, subject, "cardinality changed from %s to %s", fieldLabel(oldField), fieldLabel(newField))
+			continue
+		}
+		c.checkFieldType(subject, oldField, newField)
+	}
+}
+
+func fieldLabel(fd *desc.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return "map"
+	case fd.IsRepeated():
+		return "repeated"
+	default:
+		return "singular"
+	}
+}
+
+func (c *compatChecker) checkFieldType(subject string, oldField, newField *desc.FieldDescriptor) {
+	oldType, newType := oldField.GetType(), newField.GetType()
+	if oldType != newType {
+		oldGroup, ok := wireCompatibleTypes[oldType]
+		wire := !ok || oldGroup != wireCompatibleTypes[newType]
+		c.add(wire, true, subject, "type changed from %s to %s", fieldTypeName(oldField), fieldTypeName(newField))
+		return
+	}
+
+	switch oldType {
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE, dpb.FieldDescriptorProto_TYPE_GROUP:
+		c.checkMessage(oldField.GetMessageType(), newField.GetMessageType())
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		c.checkEnum(oldField.GetEnumType(), newField.GetEnumType())
+	}
+}
+
+func fieldTypeName(fd *desc.FieldDescriptor) string {
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE, dpb.FieldDescriptorProto_TYPE_GROUP:
+		return fd.GetMessageType().GetFullyQualifiedName()
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		return fd.GetEnumType().GetFullyQualifiedName()
+	default:
+		return strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_"))
+	}
+}
+
+func (c *compatChecker) checkEnum(oldEnum, newEnum *desc.EnumDescriptor) {
+	key := oldEnum.GetFullyQualifiedName() + " " + newEnum.GetFullyQualifiedName()
+	if c.visited[key] {
+		return
+	}
+	c.visited[key] = true
+
+	for _, oldValue := range oldEnum.GetValues() {
+		subject := oldEnum.GetFullyQualifiedName() + "." + oldValue.GetName()
+		newValue := newEnum.FindValueByNumber(oldValue.GetNumber())
+		if newValue == nil {
+			c.add(true, true, subject, "enum value %d removed", oldValue.GetNumber())
+			continue
+		}
+		if newEnum.FindValueByName(oldValue.GetName()) == nil {
+			c.add(false, true, subject, "enum value %d renamed to %s", oldValue.GetNumber(), newValue.GetName())
+		}
+	}
+}
+
+// CheckCompatibility returns the changes from oldSrc to newSrc breaking
+// existing clients.
+func CheckCompatibility(oldSrc, newSrc DescriptorSource) ([]compatChange, error) {
+	c := &compatChecker{visited: map[string]bool{}}
+	if err := c.checkSources(oldSrc, newSrc); err != nil {
+		return nil, err
+	}
+	return c.changes, nil
+}
+
+type CompatCommand struct {
+	cmd         *cobra.Command
+	opts        *GlobalOptions
+	importPaths []string
+}
+
+func NewCompatCommand(opts *GlobalOptions) *CompatCommand {
+	c := &CompatCommand{
+		cmd: &cobra.Command{
+			Use:   "compat OLD NEW",
+			Short: "Report breaking changes between two schemas",
+			Long: `Report wire- and JSON-breaking changes from OLD to NEW.
+
+Each of OLD and NEW is a protoset file, a directory of proto files or the
+address of a server supporting reflection.`,
+			Example: `
+* compat
+grpcurl compat production:8888 staging:8888
+grpcurl compat old.protoset ./proto
+`,
+			Args:         cobra.ExactArgs(2),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringArrayVarP(&c.importPaths, "import-path", "I", nil, "import path to resolve proto imports")
+	return c
+}
+
+func (c *CompatCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *CompatCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	oldSrc, closeOld, err := OpenDescriptorSource(ctx, args[0], c.opts.withoutDescriptorCache(), c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeOld()
+	newSrc, closeNew, err := OpenDescriptorSource(ctx, args[1], c.opts.withoutDescriptorCache(), c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeNew()
+
+	changes, err := CheckCompatibility(oldSrc, newSrc)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Fprintln(c.opts.Output, change)
+	}
+	if len(changes) > 0 {
+		return fmt.Errorf("%d breaking changes", len(changes))
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCompatOldProto = `syntax = "proto3";
+package compat;
+
+enum Kind {
+  UNKNOWN = 0;
+  SMALL = 1;
+  LARGE = 2;
+}
+
+message Request {
+  string name = 1;
+  int32 count = 2;
+  string id = 3;
+  Kind kind = 4;
+  repeated string tags = 5;
+  string note = 6;
+}
+
+service Svc {
+  rpc Get(Request) returns (Request);
+  rpc Watch(Request) returns (stream Request);
+  rpc Delete(Request) returns (Request);
+}
+
+service Removed {
+  rpc Get(Request) returns (Request);
+}
+`
+
+const testCompatNewProto = `syntax = "proto3";
+package compat;
+
+enum Kind {
+  UNKNOWN = 0;
+  SMALL = 1;
+  HUGE = 3;
+}
+
+message Request {
+  string display_name = 1;
+  int64 count = 2;
+  int64 id = 3;
+  Kind kind = 4;
+  string tags = 5;
+  string note = 6 [json_name = "comment"];
+  string added = 7;
+}
+
+service Svc {
+  rpc Get(Request) returns (Request);
+  rpc Watch(Request) returns (Request);
+}
+`
+
+func writeCompatProto(t *testing.T, content string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "compat"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "compat", "compat.proto"), []byte(content), 0644))
+	return dir
+}
+
+func testCompat(args ...string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "compat"}, args...))
+	return buf, cmd.Command().Execute()
+}
+
+func TestCompat(t *testing.T) {
+	oldDir := writeCompatProto(t, testCompatOldProto)
+	newDir := writeCompatProto(t, testCompatNewProto)
+
+	buf, err := testCompat(oldDir, newDir)
+	assert.EqualError(t, err, "9 breaking changes")
+	expected := `[wire,json] compat.Removed: service removed
+[json] compat.Request.name: field 1 renamed to display_name
+[json] compat.Request.count: type changed from int32 to int64
+[wire,json] compat.Request.id: type changed from string to int64
+[wire,json] compat.Kind.LARGE: enum value 2 removed
+[wire,json] compat.Request.tags: cardinality changed from repeated to singular
+[json] compat.Request.note: JSON name changed from note to comment
+[wire,json] compat.Svc.Watch: streaming mode changed from server streaming to unary
+[wire,json] compat.Svc.Delete: method removed
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestCompatSame(t *testing.T) {
+	buf, err := testCompat(addr, addr)
+	require.NoError(t, err)
+	assert.Equal(t, "", buf.String())
+
+	dir := writeCompatProto(t, testCompatOldProto)
+	buf, err = testCompat(dir, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "", buf.String())
+}
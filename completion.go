@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// completionTimeout bounds the reflection calls made to complete an
+	// argument, so that an unreachable server doesn't hang the shell.
+	completionTimeout = 5 * time.Second
+
+	maxAddressHistory = 50
+)
+
+// addressHistory is a file of the server addresses used most recently, one
+// per line, most recent first.
+type addressHistory struct {
+	path string
+}
+
+func newAddressHistory() (*addressHistory, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &addressHistory{path: filepath.Join(dir, "grpcurl", "history")}, nil
+}
+
+// Load returns the addresses in the history, most recent first.
+func (h *addressHistory) Load() []string {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var addrs []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if addr := strings.TrimSpace(s.Text()); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// Add moves addr to the top of the history, dropping the oldest addresses
+// beyond maxAddressHistory.
+func (h *addressHistory) Add(addr string) error {
+	addrs := []string{addr}
+	for _, a := range h.Load() {
+		if a != addr && len(addrs) < maxAddressHistory {
+			addrs = append(addrs, a)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.path, []byte(strings.Join(addrs, "\n")+"\n"), 0644)
+}
+
+// recordAddress adds addr to the address history. Failures are ignored as
+// the history only serves completion.
+func recordAddress(addr string) {
+	if h, err := newAddressHistory(); err == nil {
+		h.Add(addr)
+	}
+}
+
+// completeAddress completes an address from the address history.
+func completeAddress(toComplete string) ([]string, cobra.ShellCompDirective) {
+	h, err := newAddressHistory()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var addrs []string
+	for _, addr := range h.Load() {
+		if strings.HasPrefix(addr, toComplete) {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeFromServer completes with the names returned by names from the
+// descriptors of the server at addr, fetched by reflection or from the
+// descriptor cache.
+func completeFromServer(opts *GlobalOptions, addr, toComplete string,
+	names func(DescriptorSource) ([]string, error)) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+
+	conn, err := NewGRPCConnection(ctx, addr, opts)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer conn.Close()
+	src, err := NewReflectionSource(ctx, conn, addr, opts)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	all, err := names(src)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var matches []string
+	for _, name := range all {
+		if strings.HasPrefix(name, toComplete) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeService completes a FULL_SERVICE_NAME of the server at addr.
+func completeService(opts *GlobalOptions, addr, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeFromServer(opts, addr, toComplete, func(src DescriptorSource) ([]string, error) {
+		return src.ListServices()
+	})
+}
+
+// completeMethod completes a FULL_METHOD_NAME of the server at addr.
+func completeMethod(opts *GlobalOptions, addr, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeFromServer(opts, addr, toComplete, func(src DescriptorSource) ([]string, error) {
+		svcs, err := src.ListServices()
+		if err != nil {
+			return nil, err
+		}
+		var methods []string
+		for _, name := range svcs {
+			// only resolve the services the method may belong to
+			if !strings.HasPrefix(name, toComplete) && !strings.HasPrefix(toComplete, name+".") {
+				continue
+			}
+			sd, err := src.ResolveService(name)
+			if err != nil {
+				continue
+			}
+			for _, md := range sd.GetMethods() {
+				methods = append(methods, md.GetFullyQualifiedName())
+			}
+		}
+		return methods, nil
+	})
+}
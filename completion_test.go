@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testComplete(t *testing.T, args ...string) []string {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetOut(buf)
+	cmd.Command().SetArgs(append([]string{cobra.ShellCompRequestCmd, "-k"}, args...))
+	require.NoError(t, cmd.Command().Execute())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// the last line is the directive
+	assert.Equal(t, fmt.Sprintf(":%d", cobra.ShellCompDirectiveNoFileComp), lines[len(lines)-1])
+	return lines[:len(lines)-1]
+}
+
+func TestCompleteService(t *testing.T) {
+	assert.Equal(t, []string{"grpcurl.test.Echo", "grpcurl.test.Everything"},
+		testComplete(t, "ls", addr, "grpcurl.test.E"))
+}
+
+func TestCompleteMethod(t *testing.T) {
+	assert.Equal(t, []string{"grpcurl.test.v2.Echo.Echo"},
+		testComplete(t, "call", addr, "grpcurl.test.v2."))
+	assert.Equal(t, []string{"grpcurl.test.Echo.ServerStreamingEcho"},
+		testComplete(t, "call", addr, "grpcurl.test.Echo.S"))
+}
+
+func TestCompleteAddress(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "ls", addr})
+	require.NoError(t, cmd.Command().Execute())
+
+	assert.Contains(t, testComplete(t, "call", "local"), addr)
+	assert.Empty(t, testComplete(t, "ls", "unknown"))
+}
+
+func TestAddressHistory(t *testing.T) {
+	h := &addressHistory{path: filepath.Join(t.TempDir(), "history")}
+	assert.Empty(t, h.Load())
+
+	require.NoError(t, h.Add("a:1"))
+	require.NoError(t, h.Add("b:2"))
+	require.NoError(t, h.Add("a:1"))
+	assert.Equal(t, []string{"a:1", "b:2"}, h.Load())
+
+	for i := 0; i < maxAddressHistory+10; i++ {
+		require.NoError(t, h.Add(fmt.Sprintf("host:%d", i)))
+	}
+	addrs := h.Load()
+	assert.Len(t, addrs, maxAddressHistory)
+	assert.Equal(t, fmt.Sprintf("host:%d", maxAddressHistory+9), addrs[0])
+}
@@ -1,25 +1,98 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
 	"github.com/jhump/protoreflect/grpcreflect"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 )
 
-func NewGRPCConnection(ctx context.Context, addr string, insecure bool) (*grpc.ClientConn, error) {
+// NewGRPCConnection dials addr with the TLS options of opts, followed by
+// extraOpts.
+func NewGRPCConnection(ctx context.Context, addr string, opts *GlobalOptions, extraOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	var dialOpts []grpc.DialOption
-	if insecure {
+	creds, err := opts.TransportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
 	} else {
-		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, "")))
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
+	}
+
+	return grpc.DialContext(ctx, addr, append(dialOpts, extraOpts...)...)
+}
+
+// TransportCredentials returns the TLS credentials selected by the TLS
+// options, or nil with --insecure.
+func (o *GlobalOptions) TransportCredentials() (credentials.TransportCredentials, error) {
+	if o.Insecure {
+		return nil, nil
+	}
+	tlsConfig, err := o.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// TLSConfig returns the TLS configuration selected by the TLS options.
+func (o *GlobalOptions) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{ServerName: o.ServerName}
+	if o.CACert != "" {
+		b, err := ioutil.ReadFile(o.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificate in %s", o.CACert)
+		}
+	}
+	if o.Cert != "" || o.Key != "" {
+		cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// HTTPClient returns a client of the HTTP transports verifying https servers
+// with the TLS options.
+func (o *GlobalOptions) HTTPClient() (*http.Client, error) {
+	tlsConfig, err := o.TLSConfig()
+	if err != nil {
+		return nil, err
 	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
 
-	return grpc.DialContext(ctx, addr, dialOpts...)
+// HTTP1Client returns a client like HTTPClient that never negotiates HTTP/2.
+func (o *GlobalOptions) HTTP1Client() (*http.Client, error) {
+	client, err := o.HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	transport := client.Transport.(*http.Transport)
+	transport.ForceAttemptHTTP2 = false
+	// a non-nil empty map disables HTTP/2 over TLS
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	return client, nil
 }
 
-func NewServerReflectionClient(ctx context.Context, conn *grpc.ClientConn) *grpcreflect.Client {
-	cli := rpb.NewServerReflectionClient(conn)
-	return grpcreflect.NewClient(ctx, cli)
+// NewServerReflectionClient returns a reflection client using the version of
+// server reflection selected by opts.
+func NewServerReflectionClient(ctx context.Context, conn *grpc.ClientConn, opts *GlobalOptions) *grpcreflect.Client {
+	return grpcreflect.NewClient(ctx, newReflectionStub(conn, opts))
 }
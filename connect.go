@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"golang.org/x/net/context"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	connectCodecProto = "proto"
+	connectCodecJSON  = "json"
+
+	connectCompressedFlag = 0x01
+	connectEndStreamFlag  = 0x02
+)
+
+// connectCodes maps the error codes of the Connect protocol to gRPC codes.
+var connectCodes = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+}
+
+// connectError is the JSON representation of an error in the Connect
+// protocol.
+type connectError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message,omitempty"`
+	Details []connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// status converts the error into a status. httpStatus gives the code when
+// the error has none known.
+func (e *connectError) status(httpStatus int) *status.Status {
+	code, ok := connectCodes[e.Code]
+	if !ok {
+		code = httpStatusCode(httpStatus)
+	}
+	sp := &spb.Status{Code: int32(code), Message: e.Message}
+	for _, d := range e.Details {
+		b, err := decodeBinaryHeader(d.Value)
+		if err != nil {
+			continue
+		}
+		sp.Details = append(sp.Details, &any.Any{
+			TypeUrl: "type.googleapis.com/" + d.Type,
+			Value:   b,
+		})
+	}
+	return status.FromProto(sp)
+}
+
+// connectEndStream is the last message of a Connect streaming response.
+type connectEndStream struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// connectTransport is a CallTransport speaking the Connect protocol over
+// HTTP/1.1, with messages encoded by codec.
+type connectTransport struct {
+	client      *http.Client
+	baseURL     string
+	codec       string
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func NewConnectTransport(client *http.Client, addr string, insecure bool, codec string) (CallTransport, error) {
+	if codec != connectCodecProto && codec != connectCodecJSON {
+		return nil, fmt.Errorf("unknown codec: %s", codec)
+	}
+	return &connectTransport{
+		client:      client,
+		baseURL:     httpBaseURL(addr, insecure),
+		codec:       codec,
+		marshaler:   &jsonpb.Marshaler{AnyResolver: DynamicAnyResolver{}},
+		unmarshaler: newJSONUnmarshaler(),
+	}, nil
+}
+
+func (t *connectTransport) marshal(msg proto.Message) ([]byte, error) {
+	if t.codec == connectCodecJSON {
+		s, err := t.marshaler.MarshalToString(msg)
+		return []byte(s), err
+	}
+	return proto.Marshal(msg)
+}
+
+func (t *connectTransport) unmarshal(mdesc *desc.MessageDescriptor, data []byte) (proto.Message, error) {
+	msg := dynamic.NewMessage(mdesc)
+	if t.codec == connectCodecJSON {
+		return msg, msg.UnmarshalJSONPB(t.unmarshaler, data)
+	}
+	return msg, msg.Unmarshal(data)
+}
+
+func (t *connectTransport) Invoke(ctx context.Context, mdesc *desc.MethodDescriptor, req proto.Message, onResponse func(proto.Message) error) (metadata.MD, metadata.MD, error) {
+	if mdesc.IsClientStreaming() {
+		return nil, nil, fmt.Errorf("client streaming method is not supported by Connect over HTTP/1.1")
+	}
+
+	data, err := t.marshal(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal %v", err)
+	}
+	streaming := mdesc.IsServerStreaming()
+	contentType := "application/" + t.codec
+	body := &bytes.Buffer{}
+	if streaming {
+		contentType = "application/connect+" + t.codec
+		writeFrame(body, 0, data)
+	} else {
+		body.Write(data)
+	}
+
+	u := t.baseURL + "/" + mdesc.GetService().GetFullyQualifiedName() + "/" + mdesc.GetName()
+	httpReq, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	setMetadataHeaders(ctx, httpReq.Header)
+	if ms, ok := timeoutMillis(ctx); ok {
+		httpReq.Header.Set("Connect-Timeout-Ms", ms)
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Connect-Protocol-Version", "1")
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unavailable, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if streaming {
+		return t.readStream(resp, mdesc, onResponse)
+	}
+	return t.readUnary(resp, mdesc, onResponse)
+}
+
+// readUnary reads a unary response, whose trailers are sent as headers
+// prefixed with Trailer-.
+func (t *connectTransport) readUnary(resp *http.Response, mdesc *desc.MethodDescriptor, onResponse func(proto.Message) error) (metadata.MD, metadata.MD, error) {
+	header, trailer := metadata.MD{}, metadata.MD{}
+	for k, vs := range headerMetadata(resp.Header) {
+		if strings.HasPrefix(k, "trailer-") {
+			trailer[strings.TrimPrefix(k, "trailer-")] = vs
+		} else {
+			header[k] = vs
+		}
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return header, trailer, status.Errorf(codes.Internal, "failed to read Connect response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e connectError
+		if err := json.Unmarshal(data, &e); err != nil {
+			return header, trailer, status.Errorf(httpStatusCode(resp.StatusCode), "unexpected HTTP status %s: %s", resp.Status, data)
+		}
+		return header, trailer, e.status(resp.StatusCode).Err()
+	}
+
+	msg, err := t.unmarshal(mdesc.GetOutputType(), data)
+	if err != nil {
+		return header, trailer, status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+	}
+	return header, trailer, onResponse(msg)
+}
+
+// readStream reads the enveloped messages of a streaming response up to its
+// end-stream message carrying the error and trailers.
+func (t *connectTransport) readStream(resp *http.Response, mdesc *desc.MethodDescriptor, onResponse func(proto.Message) error) (metadata.MD, metadata.MD, error) {
+	header := headerMetadata(resp.Header)
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return header, nil, status.Errorf(httpStatusCode(resp.StatusCode), "unexpected HTTP status %s: %s", resp.Status, b)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		flags, data, err := readFrame(r)
+		if err == io.EOF {
+			return header, nil, status.Error(codes.Internal, "Connect response ended without end-stream message")
+		}
+		if err != nil {
+			return header, nil, status.Errorf(codes.Internal, "failed to read Connect response: %v", err)
+		}
+		if flags&connectCompressedFlag != 0 {
+			return header, nil, status.Error(codes.Internal, "compressed Connect messages are not supported")
+		}
+		if flags&connectEndStreamFlag != 0 {
+			var end connectEndStream
+			if err := json.Unmarshal(data, &end); err != nil {
+				return header, nil, status.Errorf(codes.Internal, "malformed end-stream message: %v", err)
+			}
+			trailer := headerMetadata(http.Header(end.Metadata))
+			if end.Error != nil {
+				return header, trailer, end.Error.status(resp.StatusCode).Err()
+			}
+			return header, trailer, nil
+		}
+
+		msg, err := t.unmarshal(mdesc.GetOutputType(), data)
+		if err != nil {
+			return header, nil, status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+		}
+		if err := onResponse(msg); err != nil {
+			return header, nil, err
+		}
+	}
+}
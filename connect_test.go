@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kazegusuri/grpcurl/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConnectCall(t *testing.T, codec, method, msg string) (*bytes.Buffer, error) {
+	s := test.NewServer()
+	srv := httptest.NewServer(test.NewConnectHandler(s))
+	t.Cleanup(func() {
+		srv.Close()
+		s.Stop()
+	})
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(msg), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--protocol", "connect", "--codec", codec,
+		"-I", "internal/testdata", "--proto", "echo_service.proto",
+		srv.URL, method})
+	return buf, cmd.Command().Execute()
+}
+
+func TestConnectUnary(t *testing.T) {
+	for _, codec := range []string{"proto", "json"} {
+		t.Run(codec, func(t *testing.T) {
+			buf, err := testConnectCall(t, codec, "grpcurl.test.Echo.Echo", `{"value": "hello"}`)
+			require.NoError(t, err)
+			assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", buf.String())
+		})
+	}
+}
+
+func TestConnectServerStreaming(t *testing.T) {
+	for _, codec := range []string{"proto", "json"} {
+		t.Run(codec, func(t *testing.T) {
+			buf, err := testConnectCall(t, codec, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "hello"}`)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 10)
+			for _, line := range lines {
+				assert.Equal(t, `{"value":"hello","error_code":0}`, line)
+			}
+		})
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	for _, method := range []string{"grpcurl.test.Echo.Echo", "grpcurl.test.Echo.ServerStreamingEcho"} {
+		t.Run(method, func(t *testing.T) {
+			buf, err := testConnectCall(t, "json", method, `{"value": "oops", "error_code": 5}`)
+			require.NoError(t, err)
+			assert.Equal(t, "{\"code\":5,\"message\":\"error msg: oops\",\"details\":[]}\n", buf.String())
+		})
+	}
+}
+
+func TestConnectUnknownCodec(t *testing.T) {
+	_, err := testConnectCall(t, "xml", "grpcurl.test.Echo.Echo", `{}`)
+	require.Error(t, err)
+	assert.Equal(t, "unknown codec: xml", err.Error())
+}
+
+func TestConnectHTTP1(t *testing.T) {
+	s := test.NewServer()
+	handler := test.NewConnectHandler(s)
+	var proto string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		handler.ServeHTTP(w, r)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(func() {
+		srv.Close()
+		s.Stop()
+	})
+	cacert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(cacert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "hello"}`), buf)
+	cmd.Command().SetArgs([]string{"--cacert", cacert, "call", "--protocol", "connect",
+		"-I", "internal/testdata", "--proto", "echo_service.proto",
+		srv.URL, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", buf.String())
+	assert.Equal(t, "HTTP/1.1", proto)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawField is a field of a message in the wire format parsed without its
+// schema: a scalar value, or the fields of a nested message or group.
+type rawField struct {
+	num    protowire.Number
+	kind   string
+	value  string
+	fields []rawField
+}
+
+// parseRawMessage parses the fields of b, a message in the wire format,
+// without its schema. Length-delimited values are taken as a string when
+// they are printable UTF-8, else as a nested message when they parse as one,
+// else as bytes. Each level of nesting is parsed once.
+func parseRawMessage(b []byte) ([]rawField, error) {
+	fields := []rawField{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := rawField{num: num}
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			f.kind, f.value = "varint", strconv.FormatUint(v, 10)
+			if zz := protowire.DecodeZigZag(v); zz < 0 {
+				f.value += fmt.Sprintf(" (sint %d)", zz)
+			}
+			if int64(v) < 0 {
+				f.value += fmt.Sprintf(" (int %d)", int64(v))
+			}
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			f.kind, f.value = "fixed32", fmt.Sprintf("%d (float %v)", v, math.Float32frombits(v))
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			f.kind, f.value = "fixed64", fmt.Sprintf("%d (double %v)", v, math.Float64frombits(v))
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			if isPrintable(v) {
+				f.kind, f.value = "string", strconv.Quote(string(v))
+			} else if nested, err := parseRawMessage(v); err == nil {
+				f.kind, f.fields = "message", nested
+			} else {
+				f.kind, f.value = "bytes", fmt.Sprintf("%x", v)
+			}
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			nested, err := parseRawMessage(v)
+			if err != nil {
+				return nil, err
+			}
+			f.kind, f.fields = "group", nested
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d of field %d", typ, num)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// printRawFields dumps fields parsed by parseRawMessage, each with its
+// number, wire type and value.
+func printRawFields(w io.Writer, fields []rawField, indent string) {
+	for _, f := range fields {
+		if f.fields == nil {
+			fmt.Fprintf(w, "%s%d (%s): %s\n", indent, f.num, f.kind, f.value)
+			continue
+		}
+		fmt.Fprintf(w, "%s%d (%s): {\n", indent, f.num, f.kind)
+		printRawFields(w, f.fields, indent+"  ")
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	return strings.IndexFunc(string(b), func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0
+}
+
+type DecodeCommand struct {
+	cmd        *cobra.Command
+	opts       *GlobalOptions
+	addr       string
+	format     string
+	raw        bool
+	sourceOpts DescriptorSourceOptions
+}
+
+func NewDecodeCommand(opts *GlobalOptions) *DecodeCommand {
+	c := &DecodeCommand{
+		cmd: &cobra.Command{
+			Use:   "decode [MESSAGE]",
+			Short: "Decode a message from binary to JSON or text",
+			Long: `Decode MESSAGE read from stdin in the binary wire format to JSON, or
+protobuf text format with --format text, on stdout.
+
+The message is resolved from --protoset or --proto files, or else by
+reflection from the server at --addr. With --raw no MESSAGE is needed: the
+fields are dumped by number with their wire types and guessed values.`,
+			Example: `
+* decode
+grpcurl decode --proto test.proto test.EchoMessage < echo.bin
+grpcurl decode --format text --addr localhost:8888 test.EchoMessage < echo.bin
+
+* decode without schema
+grpcurl decode --raw < echo.bin
+`,
+			Args:         cobra.MaximumNArgs(1),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.addr, "addr", "", "address of a server supporting reflection to resolve MESSAGE")
+	c.cmd.Flags().StringVar(&c.format, "format", messageFormatJSON, "output format: json or text")
+	c.cmd.Flags().BoolVar(&c.raw, "raw", false, "dump the wire format fields without MESSAGE")
+	c.sourceOpts.AddFlags(c.cmd.Flags())
+	return c
+}
+
+func (c *DecodeCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *DecodeCommand) Run(cmd *cobra.Command, args []string) error {
+	if !c.raw && len(args) == 0 {
+		return fmt.Errorf("MESSAGE is required without --raw")
+	}
+	if c.format != messageFormatJSON && c.format != messageFormatText {
+		return fmt.Errorf("unknown format: %s", c.format)
+	}
+
+	input, err := ioutil.ReadAll(c.opts.Input)
+	if err != nil {
+		return fmt.Errorf("failed to ReadAll %v", err)
+	}
+	if c.raw {
+		fields, err := parseRawMessage(input)
+		if err != nil {
+			return fmt.Errorf("invalid wire format: %v", err)
+		}
+		printRawFields(c.opts.Output, fields, "")
+		return nil
+	}
+
+	md, err := resolveOfflineMessage(context.Background(), args[0], c.addr, &c.sourceOpts, c.opts)
+	if err != nil {
+		return err
+	}
+	msg := dynamic.NewMessage(md)
+	if err := msg.Unmarshal(input); err != nil {
+		return fmt.Errorf("unmarshal %v", err)
+	}
+
+	var out []byte
+	if c.format == messageFormatText {
+		out, err = msg.MarshalTextIndent()
+	} else {
+		out, err = msg.MarshalJSONPB(newJSONMarshaler())
+	}
+	if err != nil {
+		return fmt.Errorf("marshal %v", err)
+	}
+	fmt.Fprintf(c.opts.Output, "%s\n", strings.TrimRight(string(out), "\n"))
+	return nil
+}
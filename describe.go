@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/spf13/cobra"
+)
+
+type DescribeCommand struct {
+	cmd         *cobra.Command
+	opts        *GlobalOptions
+	importPaths []string
+}
+
+func NewDescribeCommand(opts *GlobalOptions) *DescribeCommand {
+	c := &DescribeCommand{
+		cmd: &cobra.Command{
+			Use:   "describe ADDR SYMBOL",
+			Short: "Describe a service or method",
+			Long: `Describe a service or method: the signature of each method and its
+google.api.http rule, if any. ADDR is the address of a server supporting
+reflection, a protoset file or a directory of proto files.`,
+			Example: `
+* describe a service
+grpcurl describe localhost:8888 test.EchoService
+
+* describe a method
+grpcurl describe localhost:8888 test.EchoService.Echo
+`,
+			Args:         cobra.ExactArgs(2),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringArrayVarP(&c.importPaths, "import-path", "I", nil, "import path to resolve proto imports")
+	return c
+}
+
+func (c *DescribeCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *DescribeCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	src, closeSrc, err := OpenDescriptorSource(ctx, args[0], c.opts, c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+
+	if sdesc, err := src.ResolveService(args[1]); err == nil {
+		fmt.Fprintf(c.opts.Output, "service %s\n", sdesc.GetFullyQualifiedName())
+		for _, mdesc := range sdesc.GetMethods() {
+			c.describeMethod(mdesc, mdesc.GetName(), "  ")
+		}
+		return nil
+	}
+
+	mdesc, err := resolveMethod(src, args[1])
+	if err != nil {
+		return fmt.Errorf("symbol couldn't be resolved as a service or method: %s: %v", args[1], err)
+	}
+	c.describeMethod(mdesc, mdesc.GetFullyQualifiedName(), "")
+	return nil
+}
+
+// describeMethod prints the signature of mdesc in proto syntax, followed by
+// its HTTP rule.
+func (c *DescribeCommand) describeMethod(mdesc *desc.MethodDescriptor, name, indent string) {
+	inStream, outStream := "", ""
+	if mdesc.IsClientStreaming() {
+		inStream = "stream "
+	}
+	if mdesc.IsServerStreaming() {
+		outStream = "stream "
+	}
+	fmt.Fprintf(c.opts.Output, "%srpc %s(%s%s) returns (%s%s)\n", indent, name,
+		inStream, mdesc.GetInputType().GetFullyQualifiedName(),
+		outStream, mdesc.GetOutputType().GetFullyQualifiedName())
+	if rule := httpRule(mdesc); rule != nil {
+		fmt.Fprintf(c.opts.Output, "%s  http: %s\n", indent, formatHTTPRule(rule))
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/spf13/pflag"
+)
+
+// DescriptorSource resolves services and messages by their fully-qualified
+// names. *grpcreflect.Client satisfies it, as does FileDescriptorSource.
+type DescriptorSource interface {
+	ListServices() ([]string, error)
+	ResolveService(serviceName string) (*desc.ServiceDescriptor, error)
+	ResolveMessage(messageName string) (*desc.MessageDescriptor, error)
+}
+
+// FileDescriptorSource is a DescriptorSource backed by file descriptors
+// loaded from protoset files or parsed from proto sources.
+type FileDescriptorSource struct {
+	files    []*desc.FileDescriptor
+	services map[string]*desc.ServiceDescriptor
+	messages map[string]*desc.MessageDescriptor
+}
+
+// NewFileDescriptorSource creates a FileDescriptorSource from the given files
+// and all of their transitive dependencies.
+func NewFileDescriptorSource(fds ...*desc.FileDescriptor) *FileDescriptorSource {
+	s := &FileDescriptorSource{
+		services: map[string]*desc.ServiceDescriptor{},
+		messages: map[string]*desc.MessageDescriptor{},
+	}
+	seen := map[string]bool{}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		s.files = append(s.files, fd)
+		for _, sd := range fd.GetServices() {
+			s.services[sd.GetFullyQualifiedName()] = sd
+		}
+		for _, md := range fd.GetMessageTypes() {
+			s.addMessage(md)
+		}
+	}
+	for _, fd := range fds {
+		add(fd)
+	}
+	return s
+}
+
+func (s *FileDescriptorSource) addMessage(md *desc.MessageDescriptor) {
+	s.messages[md.GetFullyQualifiedName()] = md
+	for _, nested := range md.GetNestedMessageTypes() {
+		s.addMessage(nested)
+	}
+}
+
+// NewDescriptorSourceFromProtoSets loads FileDescriptorSets, as emitted by
+// protoc --descriptor_set_out --include_imports, from the given files.
+func NewDescriptorSourceFromProtoSets(filenames ...string) (*FileDescriptorSource, error) {
+	var protos []*dpb.FileDescriptorProto
+	for _, filename := range filenames {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read protoset: %v", err)
+		}
+		var set dpb.FileDescriptorSet
+		if err := proto.Unmarshal(b, &set); err != nil {
+			return nil, fmt.Errorf("failed to parse protoset %s: %v", filename, err)
+		}
+		protos = append(protos, set.GetFile()...)
+	}
+
+	files, err := desc.CreateFileDescriptors(protos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create descriptors: %v", err)
+	}
+	fds := make([]*desc.FileDescriptor, 0, len(protos))
+	for _, fdp := range protos {
+		fds = append(fds, files[fdp.GetName()])
+	}
+	return NewFileDescriptorSource(fds...), nil
+}
+
+// NewDescriptorSourceFromProtoFiles parses the given proto source files,
+// resolving imports against importPaths.
+func NewDescriptorSourceFromProtoFiles(importPaths []string, filenames ...string) (*FileDescriptorSource, error) {
+	p := protoparse.Parser{
+		ImportPaths:           importPaths,
+		IncludeSourceCodeInfo: true,
+	}
+	fds, err := p.ParseFiles(filenames...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proto files: %v", err)
+	}
+	// protoparse adds the source code info to the descriptor protos after
+	// creating the descriptors, which thus lack comments until recreated
+	if fds, err = recreateFileDescriptors(fds); err != nil {
+		return nil, fmt.Errorf("failed to create descriptors: %v", err)
+	}
+	return NewFileDescriptorSource(fds...), nil
+}
+
+// recreateFileDescriptors creates the given files again, with their
+// dependencies, from their descriptor protos.
+func recreateFileDescriptors(fds []*desc.FileDescriptor) ([]*desc.FileDescriptor, error) {
+	var protos []*dpb.FileDescriptorProto
+	seen := map[string]bool{}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		protos = append(protos, fd.AsFileDescriptorProto())
+	}
+	for _, fd := range fds {
+		add(fd)
+	}
+
+	files, err := desc.CreateFileDescriptors(protos)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*desc.FileDescriptor, len(fds))
+	for i, fd := range fds {
+		res[i] = files[fd.GetName()]
+	}
+	return res, nil
+}
+
+// Files returns all files of the source, dependencies before dependents.
+func (s *FileDescriptorSource) Files() []*desc.FileDescriptor {
+	return s.files
+}
+
+// ListServices implements DescriptorSource.ListServices
+func (s *FileDescriptorSource) ListServices() ([]string, error) {
+	svcs := make([]string, 0, len(s.services))
+	for name := range s.services {
+		svcs = append(svcs, name)
+	}
+	sort.Strings(svcs)
+	return svcs, nil
+}
+
+// ResolveService implements DescriptorSource.ResolveService
+func (s *FileDescriptorSource) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	sd, ok := s.services[serviceName]
+	if !ok {
+		return nil, fmt.Errorf("service not found: %s", serviceName)
+	}
+	return sd, nil
+}
+
+// ResolveMessage implements DescriptorSource.ResolveMessage
+func (s *FileDescriptorSource) ResolveMessage(messageName string) (*desc.MessageDescriptor, error) {
+	md, ok := s.messages[messageName]
+	if !ok {
+		return nil, fmt.Errorf("message not found: %s", messageName)
+	}
+	return md, nil
+}
+
+// DescriptorSourceOptions holds the flags selecting a file-based descriptor
+// source.
+type DescriptorSourceOptions struct {
+	ProtoSets   []string
+	ProtoFiles  []string
+	ImportPaths []string
+}
+
+func (o *DescriptorSourceOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringArrayVar(&o.ProtoSets, "protoset", nil, "protoset file containing a FileDescriptorSet")
+	fs.StringArrayVar(&o.ProtoFiles, "proto", nil, "proto source file")
+	fs.StringArrayVarP(&o.ImportPaths, "import-path", "I", nil, "import path to resolve proto imports")
+}
+
+// IsSet reports whether any protoset or proto file was given.
+func (o *DescriptorSourceOptions) IsSet() bool {
+	return len(o.ProtoSets) > 0 || len(o.ProtoFiles) > 0
+}
+
+// Load loads the descriptor source selected by the options.
+func (o *DescriptorSourceOptions) Load() (*FileDescriptorSource, error) {
+	if len(o.ProtoSets) > 0 && len(o.ProtoFiles) > 0 {
+		return nil, fmt.Errorf("--protoset and --proto are mutually exclusive")
+	}
+	if len(o.ProtoSets) > 0 {
+		return NewDescriptorSourceFromProtoSets(o.ProtoSets...)
+	}
+	if len(o.ProtoFiles) > 0 {
+		return NewDescriptorSourceFromProtoFiles(o.ImportPaths, o.ProtoFiles...)
+	}
+	return nil, fmt.Errorf("either --protoset or --proto is required")
+}
+
+// NewDescriptorSourceFromProtoDir parses every proto source file under dir,
+// which is used as the first import path.
+func NewDescriptorSourceFromProtoDir(dir string, importPaths []string) (*FileDescriptorSource, error) {
+	var filenames []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".proto") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		filenames = append(filenames, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proto directory: %v", err)
+	}
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no proto files in %s", dir)
+	}
+	return NewDescriptorSourceFromProtoFiles(append([]string{dir}, importPaths...), filenames...)
+}
+
+// OpenDescriptorSource opens the descriptor source named by spec: a protoset
+// file, a directory of proto sources or else the address of a server
+// supporting reflection. The returned function releases the source.
+func OpenDescriptorSource(ctx context.Context, spec string, opts *GlobalOptions, importPaths []string) (DescriptorSource, func(), error) {
+	if info, err := os.Stat(spec); err == nil {
+		var src *FileDescriptorSource
+		if info.IsDir() {
+			src, err = NewDescriptorSourceFromProtoDir(spec, importPaths)
+		} else {
+			src, err = NewDescriptorSourceFromProtoSets(spec)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return src, func() {}, nil
+	}
+
+	conn, err := NewGRPCConnection(ctx, spec, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := NewReflectionSource(ctx, conn, spec, opts)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return src, func() { conn.Close() }, nil
+}
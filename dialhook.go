@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// dialHook is notified of the phases of dialing a connection: the name
+// lookup, the TCP connection and the TLS handshake.
+type dialHook interface {
+	HandleDial(e *dialEvent)
+}
+
+// dialPhase is the start or the end of a phase of dialing a connection.
+type dialPhase int
+
+const (
+	dialLookupStart dialPhase = iota
+	dialLookupDone
+	dialConnectStart
+	dialConnectDone
+	dialHandshakeStart
+	dialHandshakeDone
+)
+
+func (p dialPhase) String() string {
+	switch p {
+	case dialLookupStart:
+		return "LookupStart"
+	case dialLookupDone:
+		return "LookupDone"
+	case dialConnectStart:
+		return "ConnectStart"
+	case dialConnectDone:
+		return "ConnectDone"
+	case dialHandshakeStart:
+		return "HandshakeStart"
+	case dialHandshakeDone:
+		return "HandshakeDone"
+	}
+	return "Unknown"
+}
+
+// dialEvent is an event of dialing a connection. The events of a dial share
+// its ID, and so do those of a handshake.
+type dialEvent struct {
+	Phase dialPhase
+	ID    int
+	// Addr is the host looked up, the address connected, or the remote
+	// address of the handshake.
+	Addr string
+	// Err is the failure ending the phase, if any.
+	Err error
+}
+
+// dialHooks passes the events of dialing connections to each of its hooks,
+// as a connection takes a single dialer and credentials.
+type dialHooks struct {
+	hooks  []dialHook
+	lastID int32
+}
+
+func (h *dialHooks) Add(hook dialHook) {
+	h.hooks = append(h.hooks, hook)
+}
+
+func (h *dialHooks) handle(e *dialEvent) {
+	for _, hook := range h.hooks {
+		hook.HandleDial(e)
+	}
+}
+
+func (h *dialHooks) nextID() int {
+	return int(atomic.AddInt32(&h.lastID, 1))
+}
+
+// DialOptions returns the options hooking h into the dial of a connection
+// with the credentials of opts, or none without hooks.
+func (h *dialHooks) DialOptions(opts *GlobalOptions) ([]grpc.DialOption, error) {
+	if len(h.hooks) == 0 {
+		return nil, nil
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithContextDialer(h.dial),
+	}
+	creds, err := opts.TransportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(&hookedCredentials{TransportCredentials: creds, hooks: h}))
+	}
+	return dialOpts, nil
+}
+
+// dial looks up the host of addr and connects to its addresses in turn.
+func (h *dialHooks) dial(ctx context.Context, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	id := h.nextID()
+	h.handle(&dialEvent{Phase: dialLookupStart, ID: id, Addr: host})
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	h.handle(&dialEvent{Phase: dialLookupDone, ID: id, Addr: host, Err: err})
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	for _, ip := range ips {
+		target := net.JoinHostPort(ip, port)
+		h.handle(&dialEvent{Phase: dialConnectStart, ID: id, Addr: target})
+		var conn net.Conn
+		conn, err = d.DialContext(ctx, "tcp", target)
+		h.handle(&dialEvent{Phase: dialConnectDone, ID: id, Addr: target, Err: err})
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// hookedCredentials reports the TLS handshakes of connections to hooks.
+type hookedCredentials struct {
+	credentials.TransportCredentials
+	hooks *dialHooks
+}
+
+func (c *hookedCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	id := c.hooks.nextID()
+	remote := conn.RemoteAddr().String()
+	c.hooks.handle(&dialEvent{Phase: dialHandshakeStart, ID: id, Addr: remote})
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+	c.hooks.handle(&dialEvent{Phase: dialHandshakeDone, ID: id, Addr: remote, Err: err})
+	return conn, info, err
+}
+
+func (c *hookedCredentials) Clone() credentials.TransportCredentials {
+	return &hookedCredentials{TransportCredentials: c.TransportCredentials.Clone(), hooks: c.hooks}
+}
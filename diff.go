@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/metadata"
+)
+
+// jsonDiff is a difference at Path. HasA or HasB is false when the value
+// exists only on the other side, which tells a missing value from null.
+type jsonDiff struct {
+	Path string
+	A    interface{}
+	B    interface{}
+	HasA bool
+	HasB bool
+}
+
+func (d jsonDiff) String() string {
+	switch {
+	case !d.HasA:
+		return fmt.Sprintf("+ %s: %s", d.Path, compactJSON(d.B))
+	case !d.HasB:
+		return fmt.Sprintf("- %s: %s", d.Path, compactJSON(d.A))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", d.Path, compactJSON(d.A), compactJSON(d.B))
+	}
+}
+
+func compactJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+var identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func appendJSONPathKey(path, key string) string {
+	if identPattern.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+// diffJSON returns the differences between a and b, values decoded by
+// encoding/json, ignoring object key order. hasA and hasB tell whether the
+// values exist at all. Paths matched by ignore are skipped.
+func diffJSON(path string, a interface{}, hasA bool, b interface{}, hasB bool, ignore []*regexp.Regexp) []jsonDiff {
+	for _, re := range ignore {
+		if re.MatchString(path) {
+			return nil
+		}
+	}
+	if !hasA || !hasB {
+		if !hasA && !hasB {
+			return nil
+		}
+		return []jsonDiff{{Path: path, A: a, B: b, HasA: hasA, HasB: hasB}}
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]struct{}{}
+		for k := range av {
+			keys[k] = struct{}{}
+		}
+		for k := range bv {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diffs []jsonDiff
+		for _, k := range sorted {
+			ae, aok := av[k]
+			be, bok := bv[k]
+			diffs = append(diffs, diffJSON(appendJSONPathKey(path, k), ae, aok, be, bok, ignore)...)
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		var diffs []jsonDiff
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			var ae, be interface{}
+			if i < len(av) {
+				ae = av[i]
+			}
+			if i < len(bv) {
+				be = bv[i]
+			}
+			diffs = append(diffs, diffJSON(fmt.Sprintf("%s[%d]", path, i), ae, i < len(av), be, i < len(bv), ignore)...)
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []jsonDiff{{Path: path, A: a, B: b, HasA: true, HasB: true}}
+}
+
+// compileIgnorePath compiles a JSON path, in which wildcards match any key or
+// index, into a pattern matching the path and everything below it.
+func compileIgnorePath(path string) (*regexp.Regexp, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	expr := `^\$`
+	for _, s := range steps {
+		switch {
+		case s.wildcard:
+			expr += `(\.[A-Za-z0-9_]+|\["(\\.|[^"])*"\]|\[\d+\])`
+		case s.isIndex:
+			expr += regexp.QuoteMeta(fmt.Sprintf("[%d]", s.index))
+		default:
+			expr += regexp.QuoteMeta(appendJSONPathKey("", s.key))
+		}
+	}
+	return regexp.Compile(expr + `([.\[]|$)`)
+}
+
+// diffMetadata returns the differences between a and b for the keys present in
+// either.
+func diffMetadata(a, b metadata.MD) []jsonDiff {
+	keys := map[string]struct{}{}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diffs []jsonDiff
+	for _, k := range sorted {
+		d := jsonDiff{Path: k}
+		var vs []string
+		if vs, d.HasA = a[k]; d.HasA {
+			d.A = strings.Join(vs, ", ")
+		}
+		if vs, d.HasB = b[k]; d.HasB {
+			d.B = strings.Join(vs, ", ")
+		}
+		if d.HasA != d.HasB || d.A != d.B {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs
+}
+
+type DiffCommand struct {
+	cmd            *cobra.Command
+	opts           *GlobalOptions
+	headers        []string
+	ignore         []string
+	ignoreTrailers []string
+}
+
+func NewDiffCommand(opts *GlobalOptions) *DiffCommand {
+	c := &DiffCommand{
+		cmd: &cobra.Command{
+			Use:   "diff ADDR_A ADDR_B FULL_METHOD_NAME",
+			Short: "Compare responses of two servers to the same request",
+			Example: `
+* diff
+echo '{"message": "hello"}' | grpcurl diff --ignore '$.updated_at' old:8888 new:8888 test.Test.Echo
+`,
+			Args:         cobra.ExactArgs(3),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringArrayVarP(&c.headers, "header", "H", nil, "header")
+	c.cmd.Flags().StringArrayVar(&c.ignore, "ignore", nil, "JSON path of response fields to ignore, .* and [*] match any key or index")
+	c.cmd.Flags().StringArrayVar(&c.ignoreTrailers, "ignore-trailer", nil, "trailer key to ignore")
+	return c
+}
+
+func (c *DiffCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *DiffCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	var ignore []*regexp.Regexp
+	for _, path := range c.ignore {
+		re, err := compileIgnorePath(path)
+		if err != nil {
+			return err
+		}
+		ignore = append(ignore, re)
+	}
+
+	body, err := ioutil.ReadAll(c.opts.Input)
+	if err != nil {
+		return fmt.Errorf("failed to ReadAll %v", err)
+	}
+	md := buildOutgoingMetadata(mergeHeaders(c.opts.Headers, c.headers))
+
+	var results [2]*UnaryResult
+	for i, addr := range args[:2] {
+		conn, err := NewGRPCConnection(ctx, addr, c.opts)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		src, err := NewReflectionSource(ctx, conn, addr, c.opts.withoutDescriptorCache())
+		if err != nil {
+			return err
+		}
+		invoker := NewUnaryInvoker(src, conn)
+		results[i], err = invoker.Invoke(ctx, args[2], md, body)
+		if err != nil {
+			return fmt.Errorf("%s: %v", addr, err)
+		}
+	}
+
+	n := 0
+	a, b := results[0], results[1]
+	if a.Status.Code() != b.Status.Code() || a.Status.Message() != b.Status.Message() {
+		fmt.Fprintf(c.opts.Output, "~ status: %s %q -> %s %q\n", a.Status.Code(), a.Status.Message(), b.Status.Code(), b.Status.Message())
+		n++
+	}
+
+	var av, bv interface{}
+	if a.Response != nil {
+		if err := json.Unmarshal(a.Response, &av); err != nil {
+			return err
+		}
+	}
+	if b.Response != nil {
+		if err := json.Unmarshal(b.Response, &bv); err != nil {
+			return err
+		}
+	}
+	for _, d := range diffJSON("$", av, a.Response != nil, bv, b.Response != nil, ignore) {
+		fmt.Fprintln(c.opts.Output, d)
+		n++
+	}
+
+	// content-type shows up in trailers of trailers-only responses, which
+	// is a transport detail
+	for _, k := range append([]string{"content-type"}, c.ignoreTrailers...) {
+		k = strings.ToLower(k)
+		delete(a.Trailers, k)
+		delete(b.Trailers, k)
+	}
+	for _, d := range diffMetadata(a.Trailers, b.Trailers) {
+		d.Path = "trailer " + d.Path
+		fmt.Fprintln(c.opts.Output, d)
+		n++
+	}
+
+	switch {
+	case n == 1:
+		return fmt.Errorf("1 difference")
+	case n > 1:
+		return fmt.Errorf("%d differences", n)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDiff(input string, args ...string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(input), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "diff"}, args...))
+	return buf, cmd.Command().Execute()
+}
+
+func TestDiff(t *testing.T) {
+	mockAddr := startMockServer(t)
+
+	buf, err := testDiff(`{"value": "hello"}`, addr, mockAddr, "grpcurl.test.Echo.Echo")
+	assert.EqualError(t, err, "1 difference")
+	assert.Equal(t, "~ $.value: \"hello\" -> \"world\"\n", buf.String())
+
+	buf, err = testDiff(`{"value": "hello"}`, "--ignore", "$.value", addr, mockAddr, "grpcurl.test.Echo.Echo")
+	require.NoError(t, err)
+	assert.Equal(t, "", buf.String())
+
+	buf, err = testDiff(`{"value": "other"}`, addr, mockAddr, "grpcurl.test.Echo.Echo")
+	assert.EqualError(t, err, "2 differences")
+	expected := `~ status: OK "" -> NotFound "no such value"
+- $: {"error_code":0,"value":"other"}
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestDiffJSON(t *testing.T) {
+	var a, b interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"id":1,"items":[{"name":"x","ts":"1"},{"name":"y","ts":"2"}],"only_a":true}`), &a))
+	require.NoError(t, json.Unmarshal([]byte(`{"items":[{"ts":"3","name":"x"},{"name":"z","ts":"4"},{"name":"w"}],"id":1,"d.e":null}`), &b))
+
+	diffs := diffJSON("$", a, true, b, true, nil)
+	var lines []string
+	for _, d := range diffs {
+		lines = append(lines, d.String())
+	}
+	assert.Equal(t, []string{
+		`+ $["d.e"]: null`,
+		`~ $.items[0].ts: "1" -> "3"`,
+		`~ $.items[1].name: "y" -> "z"`,
+		`~ $.items[1].ts: "2" -> "4"`,
+		`+ $.items[2]: {"name":"w"}`,
+		`- $.only_a: true`,
+	}, lines)
+
+	re, err := compileIgnorePath("$.items[*].ts")
+	require.NoError(t, err)
+	diffs = diffJSON("$", a, true, b, true, []*regexp.Regexp{re})
+	assert.Len(t, diffs, 4)
+
+	// null is a value, different from a missing one
+	require.NoError(t, json.Unmarshal([]byte(`{"x":null,"y":1}`), &a))
+	require.NoError(t, json.Unmarshal([]byte(`{"x":2,"z":null}`), &b))
+	lines = nil
+	for _, d := range diffJSON("$", a, true, b, true, nil) {
+		lines = append(lines, d.String())
+	}
+	assert.Equal(t, []string{`~ $.x: null -> 2`, `- $.y: 1`, `+ $.z: null`}, lines)
+
+	re, err = compileIgnorePath("$.items")
+	require.NoError(t, err)
+	assert.True(t, re.MatchString("$.items[0].ts"))
+	assert.False(t, re.MatchString("$.items_count"))
+
+	re, err = compileIgnorePath("$.*.ts")
+	require.NoError(t, err)
+	assert.True(t, re.MatchString(`$["a b"].ts`))
+	assert.False(t, re.MatchString(`$.a.b.ts`))
+
+	// wildcards are steps, not text replaced within keys
+	re, err = compileIgnorePath(`$["a.*b"]`)
+	require.NoError(t, err)
+	assert.True(t, re.MatchString(`$["a.*b"]`))
+	assert.False(t, re.MatchString(`$["a.xb"]`))
+	re, err = compileIgnorePath(`$.__ANY__`)
+	require.NoError(t, err)
+	assert.True(t, re.MatchString(`$.__ANY__`))
+	assert.False(t, re.MatchString(`$.other`))
+	re, err = compileIgnorePath(`$.items[*]`)
+	require.NoError(t, err)
+	assert.True(t, re.MatchString(`$.items[3].ts`))
+}
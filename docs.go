@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/spf13/cobra"
+)
+
+// apiDoc is the reference of services and the messages and enums reachable
+// from their methods.
+type apiDoc struct {
+	Title    string
+	Services []*docService
+	Messages []*docMessage
+	Enums    []*docEnum
+}
+
+type docService struct {
+	Name        string
+	Description string
+	Methods     []*docMethod
+}
+
+type docMethod struct {
+	Name            string
+	Description     string
+	Request         docType
+	Response        docType
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// docType names the type of a field or method. Anchor is set for messages
+// and enums, which have a section of their own.
+type docType struct {
+	Name   string
+	Anchor string
+}
+
+type docMessage struct {
+	Name        string
+	Description string
+	Fields      []*docField
+}
+
+type docField struct {
+	Name        string
+	Type        docType
+	KeyType     string
+	Repeated    bool
+	OneOf       string
+	Description string
+}
+
+type docEnum struct {
+	Name        string
+	Description string
+	Values      []*docEnumValue
+}
+
+type docEnumValue struct {
+	Name        string
+	Number      int32
+	Description string
+}
+
+// docBuilder collects the messages and enums reachable from services.
+type docBuilder struct {
+	doc     *apiDoc
+	visited map[string]bool
+}
+
+func newDocBuilder(title string) *docBuilder {
+	return &docBuilder{
+		doc:     &apiDoc{Title: title},
+		visited: map[string]bool{},
+	}
+}
+
+func (b *docBuilder) addService(sd *desc.ServiceDescriptor) {
+	s := &docService{
+		Name:        sd.GetFullyQualifiedName(),
+		Description: docComments(sd.GetSourceInfo()),
+	}
+	for _, mdesc := range sd.GetMethods() {
+		s.Methods = append(s.Methods, &docMethod{
+			Name:            mdesc.GetName(),
+			Description:     docComments(mdesc.GetSourceInfo()),
+			Request:         b.messageType(mdesc.GetInputType()),
+			Response:        b.messageType(mdesc.GetOutputType()),
+			ClientStreaming: mdesc.IsClientStreaming(),
+			ServerStreaming: mdesc.IsServerStreaming(),
+		})
+	}
+	b.doc.Services = append(b.doc.Services, s)
+}
+
+func (b *docBuilder) messageType(md *desc.MessageDescriptor) docType {
+	name := md.GetFullyQualifiedName()
+	if !b.visited[name] {
+		b.visited[name] = true
+		m := &docMessage{
+			Name:        name,
+			Description: docComments(md.GetSourceInfo()),
+		}
+		b.doc.Messages = append(b.doc.Messages, m)
+		for _, fd := range md.GetFields() {
+			m.Fields = append(m.Fields, b.field(fd))
+		}
+	}
+	return docType{Name: name, Anchor: name}
+}
+
+func (b *docBuilder) enumType(ed *desc.EnumDescriptor) docType {
+	name := ed.GetFullyQualifiedName()
+	if !b.visited[name] {
+		b.visited[name] = true
+		e := &docEnum{
+			Name:        name,
+			Description: docComments(ed.GetSourceInfo()),
+		}
+		for _, vd := range ed.GetValues() {
+			e.Values = append(e.Values, &docEnumValue{
+				Name:        vd.GetName(),
+				Number:      vd.GetNumber(),
+				Description: docComments(vd.GetSourceInfo()),
+			})
+		}
+		b.doc.Enums = append(b.doc.Enums, e)
+	}
+	return docType{Name: name, Anchor: name}
+}
+
+func (b *docBuilder) field(fd *desc.FieldDescriptor) *docField {
+	f := &docField{
+		Name:        fd.GetName(),
+		Repeated:    fd.IsRepeated() && !fd.IsMap(),
+		Description: docComments(fd.GetSourceInfo()),
+	}
+	if od := fd.GetOneOf(); od != nil {
+		f.OneOf = od.GetName()
+	}
+	if fd.IsMap() {
+		f.KeyType = fieldTypeName(fd.GetMapKeyType())
+		fd = fd.GetMapValueType()
+	}
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE, dpb.FieldDescriptorProto_TYPE_GROUP:
+		f.Type = b.messageType(fd.GetMessageType())
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		f.Type = b.enumType(fd.GetEnumType())
+	default:
+		f.Type = docType{Name: fieldTypeName(fd)}
+	}
+	return f
+}
+
+// build returns the reference, with messages and enums sorted by name.
+func (b *docBuilder) build() *apiDoc {
+	sort.Slice(b.doc.Messages, func(i, j int) bool { return b.doc.Messages[i].Name < b.doc.Messages[j].Name })
+	sort.Slice(b.doc.Enums, func(i, j int) bool { return b.doc.Enums[i].Name < b.doc.Enums[j].Name })
+	return b.doc
+}
+
+// docComments returns the leading comments of a location, or else its
+// trailing comments.
+func docComments(info *dpb.SourceCodeInfo_Location) string {
+	if c := strings.TrimSpace(info.GetLeadingComments()); c != "" {
+		return c
+	}
+	return strings.TrimSpace(info.GetTrailingComments())
+}
+
+var docFuncs = map[string]interface{}{
+	// cell makes s fit in a Markdown table cell
+	"cell": func(s string) string {
+		s = strings.Replace(s, "|", `\|`, -1)
+		return strings.Replace(s, "\n", "<br>", -1)
+	},
+}
+
+var markdownDocTemplate = template.Must(template.New("markdown").Funcs(docFuncs).Parse(`
+{{- define "type"}}{{if .Anchor}}[{{.Name}}](#{{.Anchor}}){{else}}{{.Name}}{{end}}{{end -}}
+# {{.Title}}
+
+## Services
+{{range .Services}}
+<a name="{{.Name}}"></a>
+### {{.Name}}
+{{if .Description}}
+{{.Description}}
+{{end}}
+| Method | Request | Response | Description |
+| ------ | ------- | -------- | ----------- |
+{{range .Methods}}| {{.Name}} | {{if .ClientStreaming}}stream {{end}}{{template "type" .Request}} | {{if .ServerStreaming}}stream {{end}}{{template "type" .Response}} | {{cell .Description}} |
+{{end}}{{end}}
+## Messages
+{{range .Messages}}
+<a name="{{.Name}}"></a>
+### {{.Name}}
+{{if .Description}}
+{{.Description}}
+{{end}}{{if .Fields}}
+| Field | Type | Label | Description |
+| ----- | ---- | ----- | ----------- |
+{{range .Fields}}| {{.Name}} | {{if .KeyType}}map<{{.KeyType}}, {{template "type" .Type}}>{{else}}{{template "type" .Type}}{{end}} | {{if .Repeated}}repeated{{end}}{{if .OneOf}}oneof {{.OneOf}}{{end}} | {{cell .Description}} |
+{{end}}{{end}}{{end}}{{if .Enums}}
+## Enums
+{{end}}{{range .Enums}}
+<a name="{{.Name}}"></a>
+### {{.Name}}
+{{if .Description}}
+{{.Description}}
+{{end}}
+| Name | Number | Description |
+| ---- | ------ | ----------- |
+{{range .Values}}| {{.Name}} | {{.Number}} | {{cell .Description}} |
+{{end}}{{end}}`))
+
+var htmlDocTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`
+{{- define "type"}}{{if .Anchor}}<a href="#{{.Anchor}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}{{end -}}
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 0 auto; padding: 1em; }
+nav ul { columns: 2; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+.description { white-space: pre-line; }
+.label { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<nav>
+<h2>Contents</h2>
+<ul>
+{{range .Services}}<li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{end}}{{range .Messages}}<li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{end}}{{range .Enums}}<li><a href="#{{.Name}}">{{.Name}}</a></li>
+{{end}}</ul>
+</nav>
+<h2>Services</h2>
+{{range .Services}}<section id="{{.Name}}">
+<h3>{{.Name}}</h3>
+{{if .Description}}<p class="description">{{.Description}}</p>
+{{end}}<table>
+<tr><th>Method</th><th>Request</th><th>Response</th><th>Description</th></tr>
+{{range .Methods}}<tr><td>{{.Name}}</td><td>{{if .ClientStreaming}}<span class="label">stream</span> {{end}}{{template "type" .Request}}</td><td>{{if .ServerStreaming}}<span class="label">stream</span> {{end}}{{template "type" .Response}}</td><td class="description">{{.Description}}</td></tr>
+{{end}}</table>
+</section>
+{{end}}<h2>Messages</h2>
+{{range .Messages}}<section id="{{.Name}}">
+<h3>{{.Name}}</h3>
+{{if .Description}}<p class="description">{{.Description}}</p>
+{{end}}{{if .Fields}}<table>
+<tr><th>Field</th><th>Type</th><th>Label</th><th>Description</th></tr>
+{{range .Fields}}<tr><td>{{.Name}}</td><td>{{if .KeyType}}map&lt;{{.KeyType}}, {{template "type" .Type}}&gt;{{else}}{{template "type" .Type}}{{end}}</td><td class="label">{{if .Repeated}}repeated{{end}}{{if .OneOf}}oneof {{.OneOf}}{{end}}</td><td class="description">{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}</section>
+{{end}}{{if .Enums}}<h2>Enums</h2>
+{{end}}{{range .Enums}}<section id="{{.Name}}">
+<h3>{{.Name}}</h3>
+{{if .Description}}<p class="description">{{.Description}}</p>
+{{end}}<table>
+<tr><th>Name</th><th>Number</th><th>Description</th></tr>
+{{range .Values}}<tr><td>{{.Name}}</td><td>{{.Number}}</td><td class="description">{{.Description}}</td></tr>
+{{end}}</table>
+</section>
+{{end}}</body>
+</html>
+`))
+
+// writeDoc writes doc in format, markdown or html.
+func writeDoc(w io.Writer, doc *apiDoc, format string) error {
+	switch format {
+	case "markdown", "md":
+		return markdownDocTemplate.Execute(w, doc)
+	case "html":
+		return htmlDocTemplate.Execute(w, doc)
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+type DocsCommand struct {
+	cmd         *cobra.Command
+	opts        *GlobalOptions
+	format      string
+	title       string
+	importPaths []string
+}
+
+func NewDocsCommand(opts *GlobalOptions) *DocsCommand {
+	c := &DocsCommand{
+		cmd: &cobra.Command{
+			Use:   "docs ADDR",
+			Short: "Generate an API reference of services",
+			Long: `Generate a Markdown or single-file HTML reference of the services of
+ADDR, and the messages and enums reachable from their methods.
+
+Comments of the proto sources are included when the descriptors carry them.
+ADDR is the address of a server supporting reflection, a protoset file or a
+directory of proto files.`,
+			Example: `
+* docs
+grpcurl docs localhost:8888 > API.md
+grpcurl docs --format html ./proto > api.html
+`,
+			Args:         cobra.ExactArgs(1),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.format, "format", "markdown", "output format: markdown or html")
+	c.cmd.Flags().StringVar(&c.title, "title", "API Reference", "title of the reference")
+	c.cmd.Flags().StringArrayVarP(&c.importPaths, "import-path", "I", nil, "import path to resolve proto imports")
+	return c
+}
+
+func (c *DocsCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *DocsCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	src, closeSrc, err := OpenDescriptorSource(ctx, args[0], c.opts, c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+
+	svcs, err := src.ListServices()
+	if err != nil {
+		return fmt.Errorf("failed to list services: %v", err)
+	}
+	sort.Strings(svcs)
+
+	b := newDocBuilder(c.title)
+	for _, name := range svcs {
+		if strings.HasPrefix(name, "grpc.reflection.") {
+			continue
+		}
+		sd, err := src.ResolveService(name)
+		if err != nil {
+			return err
+		}
+		b.addService(sd)
+	}
+	return writeDoc(c.opts.Output, b.build(), c.format)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDocs(t *testing.T, args ...string) string {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "docs"}, args...))
+	require.NoError(t, cmd.Command().Execute())
+	return buf.String()
+}
+
+func TestDocsMarkdown(t *testing.T) {
+	out := testDocs(t, addr)
+	assert.Contains(t, out, "# API Reference\n")
+	assert.NotContains(t, out, "grpc.reflection")
+	assert.Contains(t, out, "<a name=\"grpcurl.test.Echo\"></a>\n### grpcurl.test.Echo\n")
+	assert.Contains(t, out, "| ServerStreamingEcho | [grpcurl.test.EchoMessage](#grpcurl.test.EchoMessage) | stream [grpcurl.test.EchoMessage](#grpcurl.test.EchoMessage) |  |\n")
+	assert.Contains(t, out, "| mapped_nested_value | map<string, [grpcurl.test.NestedMessage](#grpcurl.test.NestedMessage)> |  |  |\n")
+	assert.Contains(t, out, "| repeated_numeric_enum_values | [grpcurl.test.NumericEnum](#grpcurl.test.NumericEnum) | repeated |  |\n")
+	assert.Contains(t, out, "| string_value | string | oneof oneof_value |  |\n")
+	assert.Contains(t, out, "<a name=\"grpcurl.test.NumericEnum\"></a>\n### grpcurl.test.NumericEnum\n")
+	assert.Contains(t, out, "| TWO | 2 |  |\n")
+}
+
+func TestDocsHTMLComments(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "doc.proto"), []byte(`syntax = "proto3";
+package doc;
+
+// Greeter greets.
+service Greeter {
+  // Hello says <hello>.
+  rpc Hello(HelloRequest) returns (stream HelloReply) {}
+}
+
+message HelloRequest {
+  string name = 1; // who to greet
+}
+
+message HelloReply {
+  string message = 1;
+}
+`), 0644))
+
+	out := testDocs(t, "--format", "html", "--title", "Greeter API", dir)
+	assert.Contains(t, out, "<title>Greeter API</title>")
+	assert.Contains(t, out, `<section id="doc.Greeter">`)
+	assert.Contains(t, out, `<p class="description">Greeter greets.</p>`)
+	assert.Contains(t, out, `<td><span class="label">stream</span> <a href="#doc.HelloReply">doc.HelloReply</a></td><td class="description">Hello says &lt;hello&gt;.</td>`)
+	assert.Contains(t, out, `<td>name</td><td>string</td><td class="label"></td><td class="description">who to greet</td>`)
+	assert.NotContains(t, out, "<h2>Enums</h2>")
+}
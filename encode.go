@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/spf13/cobra"
+)
+
+const (
+	messageFormatJSON = "json"
+	messageFormatText = "text"
+)
+
+// resolveOfflineMessage resolves the message named name from the protosets
+// or proto files of sourceOpts, or else by reflection from the server at addr.
+func resolveOfflineMessage(ctx context.Context, name, addr string, sourceOpts *DescriptorSourceOptions, opts *GlobalOptions) (*desc.MessageDescriptor, error) {
+	var src DescriptorSource
+	switch {
+	case sourceOpts.IsSet():
+		fsrc, err := sourceOpts.Load()
+		if err != nil {
+			return nil, err
+		}
+		src = fsrc
+	case addr != "":
+		conn, err := NewGRPCConnection(ctx, addr, opts)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if src, err = NewReflectionSource(ctx, conn, addr, opts); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("--protoset, --proto or --addr is required")
+	}
+
+	md, err := src.ResolveMessage(name)
+	if err != nil {
+		return nil, fmt.Errorf("message couldn't be resolved: %v", err)
+	}
+	return md, nil
+}
+
+type EncodeCommand struct {
+	cmd        *cobra.Command
+	opts       *GlobalOptions
+	addr       string
+	format     string
+	sourceOpts DescriptorSourceOptions
+}
+
+func NewEncodeCommand(opts *GlobalOptions) *EncodeCommand {
+	c := &EncodeCommand{
+		cmd: &cobra.Command{
+			Use:   "encode MESSAGE",
+			Short: "Encode a message from JSON or text to binary",
+			Long: `Encode MESSAGE read from stdin as JSON, or protobuf text format with
+--format text, to the binary wire format on stdout.
+
+The message is resolved from --protoset or --proto files, or else by
+reflection from the server at --addr.`,
+			Example: `
+* encode
+echo '{"message": "hello"}' | grpcurl encode --proto test.proto test.EchoMessage > echo.bin
+echo 'message: "hello"' | grpcurl encode --format text --addr localhost:8888 test.EchoMessage > echo.bin
+`,
+			Args:         cobra.ExactArgs(1),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.addr, "addr", "", "address of a server supporting reflection to resolve MESSAGE")
+	c.cmd.Flags().StringVar(&c.format, "format", messageFormatJSON, "input format: json or text")
+	c.sourceOpts.AddFlags(c.cmd.Flags())
+	return c
+}
+
+func (c *EncodeCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *EncodeCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.format != messageFormatJSON && c.format != messageFormatText {
+		return fmt.Errorf("unknown format: %s", c.format)
+	}
+	md, err := resolveOfflineMessage(context.Background(), args[0], c.addr, &c.sourceOpts, c.opts)
+	if err != nil {
+		return err
+	}
+
+	input, err := ioutil.ReadAll(c.opts.Input)
+	if err != nil {
+		return fmt.Errorf("failed to ReadAll %v", err)
+	}
+	msg := dynamic.NewMessage(md)
+	if c.format == messageFormatText {
+		err = msg.UnmarshalText(input)
+	} else {
+		err = msg.UnmarshalJSONPB(newJSONUnmarshaler(), input)
+	}
+	if err != nil {
+		return fmt.Errorf("unmarshal %v", err)
+	}
+
+	b, err := msg.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal %v", err)
+	}
+	_, err = c.opts.Output.Write(b)
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func testCodec(t *testing.T, input []byte, args ...string) []byte {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(bytes.NewReader(input), buf)
+	cmd.Command().SetArgs(append([]string{"-k"}, args...))
+	require.NoError(t, cmd.Command().Execute())
+	return buf.Bytes()
+}
+
+func TestEncodeDecode(t *testing.T) {
+	b := testCodec(t, []byte(`{"value": "hello", "error_code": 3}`),
+		"encode", "--addr", addr, "grpcurl.test.EchoMessage")
+	assert.Equal(t, []byte("\x0a\x05hello\x10\x03"), b)
+
+	out := testCodec(t, b, "decode", "--addr", addr, "grpcurl.test.EchoMessage")
+	assert.JSONEq(t, `{"value": "hello", "error_code": 3}`, string(out))
+}
+
+func TestEncodeDecodeText(t *testing.T) {
+	protoFile := "internal/testdata/echo_service.proto"
+	b := testCodec(t, []byte(`value: "hello" error_code: 3`),
+		"encode", "--format", "text", "--proto", protoFile, "grpcurl.test.EchoMessage")
+	assert.Equal(t, []byte("\x0a\x05hello\x10\x03"), b)
+
+	out := testCodec(t, b, "decode", "--format", "text", "--proto", protoFile, "grpcurl.test.EchoMessage")
+	assert.Equal(t, "value: \"hello\"\nerror_code: 3\n", string(out))
+}
+
+func TestDecodeRaw(t *testing.T) {
+	input := []byte("\x0a\x05hello" + // 1: string
+		"\x10\x03" + // 2: varint
+		"\x18\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01" + // 3: varint -1
+		"\x25\x00\x00\x80\x3f" + // 4: fixed32 1.0
+		"\x2a\x04\x08\x01\x10\x02" + // 5: nested message
+		"\x32\x02\xff\x00") // 6: bytes
+	out := testCodec(t, input, "decode", "--raw")
+	assert.Equal(t, strings.Join([]string{
+		`1 (string): "hello"`,
+		`2 (varint): 3 (sint -2)`,
+		`3 (varint): 18446744073709551615 (sint -9223372036854775808) (int -1)`,
+		`4 (fixed32): 1065353216 (float 1)`,
+		`5 (message): {`,
+		`  1 (varint): 1 (sint -1)`,
+		`  2 (varint): 2`,
+		`}`,
+		`6 (bytes): ff00`,
+	}, "\n")+"\n", string(out))
+}
+
+func TestDecodeRawDeep(t *testing.T) {
+	input := []byte("\x08\x01")
+	for i := 0; i < 64; i++ {
+		input = protowire.AppendBytes(protowire.AppendTag(nil, 2, protowire.BytesType), input)
+	}
+	out := testCodec(t, input, "decode", "--raw")
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	require.Len(t, lines, 64*2+1)
+	assert.Equal(t, strings.Repeat("  ", 64)+"1 (varint): 1 (sint -1)", lines[64])
+}
+
+func TestDecodeRawInvalid(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader("\x0a\x05hello\x0a\x05he"), buf)
+	cmd.Command().SetArgs([]string{"decode", "--raw"})
+	assert.Error(t, cmd.Command().Execute())
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/spf13/cobra"
+)
+
+// serviceFiles returns the files defining the services of src and all of
+// their transitive dependencies, each after its dependencies. The server
+// reflection services are skipped unless includeReflection is set.
+func serviceFiles(src DescriptorSource, includeReflection bool) ([]*desc.FileDescriptor, error) {
+	svcs, err := src.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %v", err)
+	}
+	sort.Strings(svcs)
+
+	var files []*desc.FileDescriptor
+	seen := map[string]bool{}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		files = append(files, fd)
+	}
+	for _, name := range svcs {
+		if !includeReflection && strings.HasPrefix(name, "grpc.reflection.") {
+			continue
+		}
+		sd, err := src.ResolveService(name)
+		if err != nil {
+			return nil, err
+		}
+		add(sd.GetFile())
+	}
+	return files, nil
+}
+
+type ExportCommand struct {
+	cmd         *cobra.Command
+	opts        *GlobalOptions
+	protoset    string
+	protoDir    string
+	importPaths []string
+}
+
+func NewExportCommand(opts *GlobalOptions) *ExportCommand {
+	c := &ExportCommand{
+		cmd: &cobra.Command{
+			Use:   "export ADDR",
+			Short: "Export the descriptors of services",
+			Long: `Export the file descriptors of the services of ADDR with all of their
+transitive dependencies.
+
+--protoset writes them as a FileDescriptorSet, loadable with call --protoset.
+--proto-dir writes them as reconstructed proto sources, each at its import
+path under the directory, loadable with call --proto. ADDR is the address of
+a server supporting reflection, a protoset file or a directory of proto
+files.`,
+			Example: `
+* export a protoset
+grpcurl export localhost:8888 --protoset api.pb
+
+* export proto sources
+grpcurl export localhost:8888 --proto-dir ./proto
+`,
+			Args:         cobra.ExactArgs(1),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.protoset, "protoset", "", "file to write a FileDescriptorSet to")
+	c.cmd.Flags().StringVar(&c.protoDir, "proto-dir", "", "directory to write proto sources to")
+	c.cmd.Flags().StringArrayVarP(&c.importPaths, "import-path", "I", nil, "import path to resolve proto imports")
+	return c
+}
+
+func (c *ExportCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ExportCommand) Run(cmd *cobra.Command, args []string) error {
+	if c.protoset == "" && c.protoDir == "" {
+		return fmt.Errorf("--protoset or --proto-dir is required")
+	}
+	ctx := context.Background()
+
+	src, closeSrc, err := OpenDescriptorSource(ctx, args[0], c.opts, c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+
+	files, err := serviceFiles(src, false)
+	if err != nil {
+		return err
+	}
+
+	if c.protoset != "" {
+		b, err := proto.Marshal(desc.ToFileDescriptorSet(files...))
+		if err != nil {
+			return fmt.Errorf("failed to marshal protoset: %v", err)
+		}
+		if err := ioutil.WriteFile(c.protoset, b, 0644); err != nil {
+			return fmt.Errorf("failed to write protoset: %v", err)
+		}
+	}
+	if c.protoDir != "" {
+		p := &protoprint.Printer{}
+		if err := p.PrintProtosToFileSystem(files, c.protoDir); err != nil {
+			return fmt.Errorf("failed to write proto sources: %v", err)
+		}
+	}
+	return nil
+}
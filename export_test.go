@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testExport(t *testing.T, args ...string) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "export", addr}, args...))
+	require.NoError(t, cmd.Command().Execute())
+}
+
+func TestExportProtoset(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "api.pb")
+	testExport(t, "--protoset", out)
+
+	src, err := NewDescriptorSourceFromProtoSets(out)
+	require.NoError(t, err)
+	svcs, err := src.ListServices()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"grpcurl.test.Echo", "grpcurl.test.Everything", "grpcurl.test.v2.Echo"}, svcs)
+
+	md, err := src.ResolveMessage("grpcurl.test.GoogleMessage")
+	require.NoError(t, err)
+	assert.NotNil(t, md.FindFieldByName("info"))
+}
+
+func TestExportProtoDir(t *testing.T) {
+	dir := t.TempDir()
+	testExport(t, "--proto-dir", dir)
+
+	src, err := NewDescriptorSourceFromProtoDir(dir, nil)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "internal", "testdata", "echo_service.proto"))
+	assert.FileExists(t, filepath.Join(dir, "google", "rpc", "error_details.proto"))
+
+	sd, err := src.ResolveService("grpcurl.test.v2.Echo")
+	require.NoError(t, err)
+	md := sd.FindMethodByName("Echo")
+	require.NotNil(t, md)
+	assert.Equal(t, "grpcurl.test.EchoMessage", md.GetInputType().GetFullyQualifiedName())
+}
+
+func TestExportRequiresOutput(t *testing.T) {
+	cmd := NewRootCommand(strings.NewReader(""), &bytes.Buffer{})
+	cmd.Command().SetArgs([]string{"-k", "export", addr})
+	assert.EqualError(t, cmd.Command().Execute(), "--protoset or --proto-dir is required")
+}
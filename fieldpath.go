@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// pathSegment is a field of a field path with its optional subscript:
+// a map key, an index of a repeated field, or empty to append an element.
+type pathSegment struct {
+	name      string
+	subscript string
+	indexed   bool
+}
+
+// parseFieldPath parses a field path such as a.b[key].c[].d[0].
+func parseFieldPath(path string) ([]pathSegment, error) {
+	var segs []pathSegment
+	for rest := path; ; {
+		i := strings.IndexAny(rest, ".[")
+		if i < 0 {
+			i = len(rest)
+		}
+		seg := pathSegment{name: rest[:i]}
+		if seg.name == "" {
+			return nil, fmt.Errorf("invalid field path: %s", path)
+		}
+		rest = rest[i:]
+		if strings.HasPrefix(rest, "[") {
+			j := strings.Index(rest, "]")
+			if j < 0 {
+				return nil, fmt.Errorf("invalid field path: %s (missing ])", path)
+			}
+			seg.subscript, seg.indexed = rest[1:j], true
+			rest = rest[j+1:]
+		}
+		segs = append(segs, seg)
+		if rest == "" {
+			return segs, nil
+		}
+		if !strings.HasPrefix(rest, ".") {
+			return nil, fmt.Errorf("invalid field path: %s", path)
+		}
+		rest = rest[1:]
+	}
+}
+
+// setFieldPaths sets the fields of msg given as path=value assignments.
+func setFieldPaths(msg *dynamic.Message, assignments []string, u *jsonpb.Unmarshaler) error {
+	for _, a := range assignments {
+		path, value, err := splitAssignment(a)
+		if err != nil {
+			return err
+		}
+		segs, err := parseFieldPath(path)
+		if err != nil {
+			return err
+		}
+		if err := setFieldPath(msg, segs, value, u); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// splitAssignment splits a path=value assignment at the first = outside
+// subscripts, so that map keys such as labels[a=b] may contain =.
+func splitAssignment(a string) (string, string, error) {
+	inSubscript := false
+	for i := 0; i < len(a); i++ {
+		switch a[i] {
+		case '[':
+			inSubscript = true
+		case ']':
+			inSubscript = false
+		case '=':
+			if !inSubscript {
+				return a[:i], a[i+1:], nil
+			}
+		}
+	}
+	if inSubscript {
+		return "", "", fmt.Errorf("invalid field path: %s (missing ])", a)
+	}
+	return "", "", fmt.Errorf("invalid data: %s (must be field.path=value)", a)
+}
+
+func setFieldPath(msg *dynamic.Message, segs []pathSegment, value string, u *jsonpb.Unmarshaler) error {
+	seg, rest := segs[0], segs[1:]
+	md := msg.GetMessageDescriptor()
+	fd := md.FindFieldByName(seg.name)
+	if fd == nil {
+		fd = md.FindFieldByJSONName(seg.name)
+	}
+	if fd == nil {
+		return fmt.Errorf("field %s not found in %s", seg.name, md.GetFullyQualifiedName())
+	}
+
+	switch {
+	case fd.IsMap():
+		if !seg.indexed || seg.subscript == "" {
+			return fmt.Errorf("map field %s requires a key", fd.GetName())
+		}
+		key, err := parseFieldValue(fd.GetMapKeyType(), seg.subscript, u)
+		if err != nil {
+			return fmt.Errorf("invalid key of %s: %v", fd.GetName(), err)
+		}
+		v, err := setElement(fd.GetMapValueType(), msg.GetMapField(fd, key), rest, value, u)
+		if err != nil {
+			return err
+		}
+		msg.PutMapField(fd, key, v)
+	case fd.IsRepeated():
+		if !seg.indexed {
+			if len(rest) > 0 {
+				return fmt.Errorf("repeated field %s requires [] or [index]", fd.GetName())
+			}
+			seg.subscript = ""
+		}
+		n := msg.FieldLength(fd)
+		i := n
+		if seg.subscript != "" {
+			var err error
+			if i, err = strconv.Atoi(seg.subscript); err != nil || i < 0 || i > n {
+				return fmt.Errorf("invalid index of %s: %s (length %d)", fd.GetName(), seg.subscript, n)
+			}
+		}
+		var cur interface{}
+		if i < n {
+			cur = msg.GetRepeatedField(fd, i)
+		}
+		v, err := setElement(fd, cur, rest, value, u)
+		if err != nil {
+			return err
+		}
+		if i < n {
+			msg.SetRepeatedField(fd, i, v)
+		} else {
+			msg.AddRepeatedField(fd, v)
+		}
+	default:
+		if seg.indexed {
+			return fmt.Errorf("field %s is not a map or repeated field", fd.GetName())
+		}
+		var cur interface{}
+		if msg.HasField(fd) {
+			cur = msg.GetField(fd)
+		}
+		v, err := setElement(fd, cur, rest, value, u)
+		if err != nil {
+			return err
+		}
+		msg.SetField(fd, v)
+	}
+	return nil
+}
+
+// setElement returns the value of a single element of fd, which is either
+// value itself at the end of the path, or cur (or a new message) with the
+// rest of the path set.
+func setElement(fd *desc.FieldDescriptor, cur interface{}, rest []pathSegment, value string, u *jsonpb.Unmarshaler) (interface{}, error) {
+	if len(rest) == 0 {
+		return parseFieldValue(fd, value, u)
+	}
+	if fd.GetMessageType() == nil {
+		return nil, fmt.Errorf("field %s is not a message", fd.GetName())
+	}
+	nested := dynamic.NewMessage(fd.GetMessageType())
+	if m, ok := cur.(proto.Message); ok && m != nil {
+		if err := nested.MergeFrom(m); err != nil {
+			return nil, err
+		}
+	}
+	if err := setFieldPath(nested, rest, value, u); err != nil {
+		return nil, err
+	}
+	return nested, nil
+}
+
+// stringJSONTypes are the well-known types whose JSON form is a string.
+var stringJSONTypes = map[string]bool{
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+	"google.protobuf.Timestamp":   true,
+	"google.protobuf.Duration":    true,
+	"google.protobuf.FieldMask":   true,
+}
+
+// parseFieldValue parses s as a value of fd. Enums are given by name or
+// number and bytes in base64. Messages, including well-known types, are
+// given in their JSON form. Values of the types whose JSON form is a string,
+// such as StringValue and Timestamp, are given unquoted, and other strings
+// may be left unquoted.
+func parseFieldValue(fd *desc.FieldDescriptor, s string, u *jsonpb.Unmarshaler) (interface{}, error) {
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_STRING:
+		return s, nil
+	case dpb.FieldDescriptorProto_TYPE_BYTES:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			if b, err = base64.URLEncoding.DecodeString(s); err != nil {
+				return nil, fmt.Errorf("invalid base64: %s", s)
+			}
+		}
+		return b, nil
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return strconv.ParseBool(s)
+	case dpb.FieldDescriptorProto_TYPE_INT32,
+		dpb.FieldDescriptorProto_TYPE_SINT32,
+		dpb.FieldDescriptorProto_TYPE_SFIXED32:
+		v, err := strconv.ParseInt(s, 0, 32)
+		return int32(v), err
+	case dpb.FieldDescriptorProto_TYPE_INT64,
+		dpb.FieldDescriptorProto_TYPE_SINT64,
+		dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return strconv.ParseInt(s, 0, 64)
+	case dpb.FieldDescriptorProto_TYPE_UINT32,
+		dpb.FieldDescriptorProto_TYPE_FIXED32:
+		v, err := strconv.ParseUint(s, 0, 32)
+		return uint32(v), err
+	case dpb.FieldDescriptorProto_TYPE_UINT64,
+		dpb.FieldDescriptorProto_TYPE_FIXED64:
+		return strconv.ParseUint(s, 0, 64)
+	case dpb.FieldDescriptorProto_TYPE_FLOAT:
+		v, err := parseFloat(s, 32)
+		return float32(v), err
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return parseFloat(s, 64)
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		ed := fd.GetEnumType()
+		if ev := ed.FindValueByName(s); ev != nil {
+			return ev.GetNumber(), nil
+		}
+		v, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unknown value of %s: %s", ed.GetFullyQualifiedName(), s)
+		}
+		return int32(v), nil
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE,
+		dpb.FieldDescriptorProto_TYPE_GROUP:
+		js := []byte(s)
+		if stringJSONTypes[fd.GetMessageType().GetFullyQualifiedName()] || !json.Valid(js) {
+			js, _ = json.Marshal(s)
+		}
+		msg := dynamic.NewMessage(fd.GetMessageType())
+		if err := msg.UnmarshalJSONPB(u, js); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	return nil, fmt.Errorf("unsupported type: %v", fd.GetType())
+}
+
+// parseFloat parses s as strconv.ParseFloat, also accepting the names of the
+// special values used by the JSON mapping.
+func parseFloat(s string, bitSize int) (float64, error) {
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	return strconv.ParseFloat(s, bitSize)
+}
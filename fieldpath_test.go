@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	pb "github.com/kazegusuri/grpcurl/internal/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func testFieldPathCall(t *testing.T, method, body string, data ...string) (map[string]interface{}, error) {
+	args := []string{"-k", "call", addr, method}
+	for _, d := range data {
+		args = append(args, "-d", d)
+	}
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(body), buf)
+	cmd.Command().SetArgs(args)
+	if err := cmd.Command().Execute(); err != nil {
+		return nil, err
+	}
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &resp))
+	return resp, nil
+}
+
+func TestFieldPathNested(t *testing.T) {
+	md, err := desc.LoadMessageDescriptorForMessage(&pb.NestedMessage{})
+	require.NoError(t, err)
+	msg := dynamic.NewMessage(md)
+	require.NoError(t, setFieldPaths(msg, []string{
+		"nested_value.int32_value=3",
+		"nested_value.string_value=s",
+		"repeated_nested_values[].string_value=x",
+		"repeated_nested_values[].string_value=y",
+		"repeated_nested_values[1].int32_value=0x10",
+	}, newJSONUnmarshaler()))
+
+	var nested pb.NestedMessage
+	require.NoError(t, msg.ConvertTo(&nested))
+	assert.Equal(t, int32(3), nested.NestedValue.Int32Value)
+	assert.Equal(t, "s", nested.NestedValue.StringValue)
+	require.Len(t, nested.RepeatedNestedValues, 2)
+	assert.Equal(t, "x", nested.RepeatedNestedValues[0].StringValue)
+	assert.Equal(t, "y", nested.RepeatedNestedValues[1].StringValue)
+	assert.Equal(t, int32(16), nested.RepeatedNestedValues[1].Int32Value)
+}
+
+func TestFieldPathMapAndEnum(t *testing.T) {
+	resp, err := testFieldPathCall(t, "grpcurl.test.Everything.Map", `{"mapped_value": {"a": "base"}}`,
+		"mapped_value[k]=v",
+		"mapped_enum_value[e]=TWO",
+		"mapped_nested_value[n].nested_value.string_value=s")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": "base", "k": "v"}, resp["mapped_value"])
+	assert.Equal(t, map[string]interface{}{"e": "TWO"}, resp["mapped_enum_value"])
+	assert.Equal(t, "s", resp["mapped_nested_value"].(map[string]interface{})["n"].(map[string]interface{})["nested_value"].(map[string]interface{})["string_value"])
+
+	resp, err = testFieldPathCall(t, "grpcurl.test.Everything.Enum", "",
+		"numeric_enum_value=1",
+		"nested_enum_value=COMPLETED",
+		"repeated_numeric_enum_values=TWO")
+	require.NoError(t, err)
+	assert.Equal(t, "ONE", resp["numeric_enum_value"])
+	assert.Equal(t, "COMPLETED", resp["nested_enum_value"])
+	assert.Equal(t, []interface{}{"TWO"}, resp["repeated_numeric_enum_values"])
+}
+
+func TestFieldPathOverridesInput(t *testing.T) {
+	resp, err := testFieldPathCall(t, "grpcurl.test.Everything.Number", `{"int32_value": 1, "int64_value": 2}`,
+		"int32_value=-5", "uint64_value=7", "double_value=1.5", "float_value=2.5e-1")
+	require.NoError(t, err)
+	assert.Equal(t, -5.0, resp["int32_value"])
+	assert.EqualValues(t, 2, resp["int64_value"])
+	assert.EqualValues(t, 7, resp["uint64_value"])
+	assert.Equal(t, 1.5, resp["double_value"])
+	assert.Equal(t, 0.25, resp["float_value"])
+}
+
+func TestFieldPathWellKnownTypes(t *testing.T) {
+	md, err := desc.LoadMessageDescriptorForMessage(&errdetails.RetryInfo{})
+	require.NoError(t, err)
+	msg := dynamic.NewMessage(md)
+	require.NoError(t, setFieldPaths(msg, []string{"retry_delay=1.5s"}, newJSONUnmarshaler()))
+	var info errdetails.RetryInfo
+	require.NoError(t, msg.ConvertTo(&info))
+	assert.Equal(t, int64(1), info.RetryDelay.Seconds)
+	assert.Equal(t, int32(500000000), info.RetryDelay.Nanos)
+
+	md, err = desc.LoadMessageDescriptorForMessage(&wrappers.BytesValue{})
+	require.NoError(t, err)
+	msg = dynamic.NewMessage(md)
+	require.NoError(t, setFieldPaths(msg, []string{"value=aGk="}, newJSONUnmarshaler()))
+	assert.Equal(t, []byte("hi"), msg.GetFieldByName("value"))
+}
+
+func TestFieldPathStringWrappers(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wrappers.proto"), []byte(`syntax = "proto3";
+import "google/protobuf/wrappers.proto";
+message Wrappers {
+  google.protobuf.StringValue string_value = 1;
+  google.protobuf.BytesValue bytes_value = 2;
+}
+`), 0644))
+	fds, err := (&protoparse.Parser{ImportPaths: []string{dir}}).ParseFiles("wrappers.proto")
+	require.NoError(t, err)
+	md := fds[0].FindMessage("Wrappers")
+
+	// values that would be valid JSON of other types are strings
+	for _, v := range []string{"123", "true", "null", `"q"`} {
+		msg := dynamic.NewMessage(md)
+		require.NoError(t, setFieldPaths(msg, []string{"string_value=" + v}, newJSONUnmarshaler()))
+		var w wrappers.StringValue
+		require.NoError(t, msg.GetFieldByName("string_value").(*dynamic.Message).ConvertTo(&w))
+		assert.Equal(t, v, w.Value)
+	}
+
+	msg := dynamic.NewMessage(md)
+	require.NoError(t, setFieldPaths(msg, []string{"bytes_value=MTIz"}, newJSONUnmarshaler()))
+	var w wrappers.BytesValue
+	require.NoError(t, msg.GetFieldByName("bytes_value").(*dynamic.Message).ConvertTo(&w))
+	assert.Equal(t, []byte("123"), w.Value)
+}
+
+func TestFieldPathMapKeyWithEquals(t *testing.T) {
+	resp, err := testFieldPathCall(t, "grpcurl.test.Everything.Map", "", "mapped_value[a=b]=v=w")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a=b": "v=w"}, resp["mapped_value"])
+}
+
+func TestFieldPathErrors(t *testing.T) {
+	_, err := testFieldPathCall(t, "grpcurl.test.Everything.Oneof", "", "int32_value")
+	assert.EqualError(t, err, "invalid data: int32_value (must be field.path=value)")
+
+	_, err = testFieldPathCall(t, "grpcurl.test.Everything.Oneof", "", "unknown=1")
+	assert.EqualError(t, err, "unknown: field unknown not found in grpcurl.test.OneofMessage")
+
+	_, err = testFieldPathCall(t, "grpcurl.test.Everything.Oneof", "", "repeated_oneof_values[2].int32_value=1")
+	assert.EqualError(t, err, "repeated_oneof_values[2].int32_value: invalid index of repeated_oneof_values: 2 (length 0)")
+
+	_, err = testFieldPathCall(t, "grpcurl.test.Everything.Map", "", "mapped_value=v")
+	assert.EqualError(t, err, "mapped_value: map field mapped_value requires a key")
+
+	_, err = testFieldPathCall(t, "grpcurl.test.Everything.Enum", "", "numeric_enum_value=THREE")
+	assert.EqualError(t, err, "numeric_enum_value: unknown value of grpcurl.test.NumericEnum: THREE")
+
+	_, err = testFieldPathCall(t, "grpcurl.test.Everything.Map", "", "mapped_value[k=v")
+	assert.EqualError(t, err, "invalid field path: mapped_value[k=v (missing ])")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// fieldTree is a set of field paths, each key mapping to the paths below it.
+// An empty tree selects a whole value.
+type fieldTree map[string]fieldTree
+
+// ResponseFilter projects response messages to the fields of a field mask,
+// then selects the values matched by a JSON path.
+type ResponseFilter struct {
+	fields     fieldTree
+	selectPath string
+}
+
+// NewResponseFilter creates a ResponseFilter from a comma-separated list of
+// dotted field paths and a JSON path, either of which may be empty.
+func NewResponseFilter(fields, selectPath string) (*ResponseFilter, error) {
+	f := &ResponseFilter{selectPath: selectPath}
+	if selectPath != "" {
+		if _, err := parseJSONPath(selectPath); err != nil {
+			return nil, err
+		}
+	}
+	if fields != "" {
+		f.fields = fieldTree{}
+		for _, path := range strings.Split(fields, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				return nil, fmt.Errorf("invalid fields: %s", fields)
+			}
+			t := f.fields
+			for _, name := range strings.Split(path, ".") {
+				if name == "" {
+					return nil, fmt.Errorf("invalid field path: %s", path)
+				}
+				if t[name] == nil {
+					t[name] = fieldTree{}
+				}
+				t = t[name]
+			}
+		}
+	}
+	return f, nil
+}
+
+// Apply filters the JSON of a response, returning the lines to print in its
+// place: the projected message, or each value selected, strings unquoted.
+func (f *ResponseFilter) Apply(respJSON string) ([]string, error) {
+	if f.fields == nil && f.selectPath == "" {
+		return []string{respJSON}, nil
+	}
+	d := json.NewDecoder(strings.NewReader(respJSON))
+	// keep 64-bit integers exact
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if f.fields != nil {
+		v = projectFields(v, f.fields)
+	}
+	values := []interface{}{v}
+	if f.selectPath != "" {
+		var err error
+		if values, err = selectJSONPath(v, f.selectPath); err != nil {
+			return nil, err
+		}
+	}
+
+	lines := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			lines = append(lines, s)
+			continue
+		}
+		buf := &bytes.Buffer{}
+		e := json.NewEncoder(buf)
+		e.SetEscapeHTML(false)
+		if err := e.Encode(v); err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.TrimSuffix(buf.String(), "\n"))
+	}
+	return lines, nil
+}
+
+// projectFields returns v with only the fields of t. Paths apply to each
+// element of arrays, as field masks do to repeated fields.
+func projectFields(v interface{}, t fieldTree) interface{} {
+	if len(t) == 0 {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := map[string]interface{}{}
+		for name, sub := range t {
+			if e, ok := v[name]; ok {
+				res[name] = projectFields(e, sub)
+			}
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = projectFields(e, t)
+		}
+		return res
+	}
+	return v
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFilterCall(t *testing.T, method, body string, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(body), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "call", addr, method}, args...))
+	err := cmd.Command().Execute()
+	return buf.String(), err
+}
+
+func TestFilterSelect(t *testing.T) {
+	out, err := testFilterCall(t, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "hi"}`, "--select", "$.value")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("hi\n", 10), out)
+
+	out, err = testFilterCall(t, "grpcurl.test.Everything.Map",
+		`{"mapped_value": {"b": "2", "a": "1"}, "mapped_nested_value": {"n": {"nested_value": {"int32_value": 3}}}}`,
+		"--select", "$.mapped_value.*")
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n", out)
+
+	out, err = testFilterCall(t, "grpcurl.test.Everything.Map",
+		`{"mapped_nested_value": {"n": {"nested_value": {"int32_value": 3}}}}`,
+		"--select", "mapped_nested_value.n.nested_value")
+	require.NoError(t, err)
+	assert.Equal(t, `{"int32_value":3,"string_value":""}`+"\n", out)
+
+	_, err = testFilterCall(t, "grpcurl.test.Echo.Echo", `{}`, "--select", "$.missing")
+	assert.EqualError(t, err, `$.missing: no such field "missing"`)
+
+	_, err = testFilterCall(t, "grpcurl.test.Echo.Echo", `{}`, "--select", "$[")
+	assert.EqualError(t, err, `invalid path "$[": missing ]`)
+}
+
+func TestFilterFields(t *testing.T) {
+	out, err := testFilterCall(t, "grpcurl.test.Echo.Echo", `{"value": "hi", "error_code": 0}`, "--fields", "value")
+	require.NoError(t, err)
+	assert.Equal(t, `{"value":"hi"}`+"\n", out)
+
+	out, err = testFilterCall(t, "grpcurl.test.Everything.Oneof",
+		`{"repeated_oneof_values": [{"int32_value": 1}, {"string_value": "s"}]}`,
+		"--fields", "repeated_oneof_values.string_value")
+	require.NoError(t, err)
+	assert.Equal(t, `{"repeated_oneof_values":[{"string_value":""},{"string_value":"s"}]}`+"\n", out)
+
+	out, err = testFilterCall(t, "grpcurl.test.Everything.Map",
+		`{"mapped_nested_value": {"n": {"nested_value": {"int32_value": 3}}}}`,
+		"--fields", "mapped_nested_value.n.nested_value.int32_value", "--select", "$.mapped_nested_value.*.nested_value")
+	require.NoError(t, err)
+	assert.Equal(t, `{"int32_value":3}`+"\n", out)
+
+	_, err = testFilterCall(t, "grpcurl.test.Echo.Echo", `{}`, "--fields", "value,,error_code")
+	assert.EqualError(t, err, "invalid fields: value,,error_code")
+}
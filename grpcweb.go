@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"golang.org/x/net/context"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcWebContentType = "application/grpc-web+proto"
+
+	// grpcWebTrailerFlag marks the frame carrying trailers in a gRPC-Web
+	// response body.
+	grpcWebTrailerFlag = 0x80
+)
+
+// grpcWebTransport is a CallTransport speaking gRPC-Web over HTTP/1.1.
+type grpcWebTransport struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewGRPCWebTransport(client *http.Client, addr string, insecure bool) CallTransport {
+	return &grpcWebTransport{
+		client:  client,
+		baseURL: httpBaseURL(addr, insecure),
+	}
+}
+
+// httpBaseURL returns addr as a URL, defaulting to https unless insecure.
+func httpBaseURL(addr string, insecure bool) string {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return strings.TrimSuffix(addr, "/")
+	}
+	if insecure {
+		return "http://" + addr
+	}
+	return "https://" + addr
+}
+
+// setMetadataHeaders copies the outgoing metadata of ctx into h, encoding
+// binary values.
+func setMetadataHeaders(ctx context.Context, h http.Header) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, vs := range md {
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			h.Add(k, v)
+		}
+	}
+}
+
+// timeoutMillis returns the time left until the deadline of ctx in
+// milliseconds, at least 1.
+func timeoutMillis(ctx context.Context) (string, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return "", false
+	}
+	timeout := time.Until(deadline)
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+	return strconv.FormatInt(int64(timeout/time.Millisecond), 10), true
+}
+
+// headerMetadata converts HTTP headers into metadata, decoding binary
+// values.
+func headerMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range h {
+		k = strings.ToLower(k)
+		for _, v := range vs {
+			if strings.HasSuffix(k, "-bin") {
+				if b, err := decodeBinaryHeader(v); err == nil {
+					v = string(b)
+				}
+			}
+			md.Append(k, v)
+		}
+	}
+	return md
+}
+
+func decodeBinaryHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
+
+// statusFromMetadata returns the status carried by grpc-status, grpc-message
+// and grpc-status-details-bin, or nil if md has no grpc-status.
+func statusFromMetadata(md metadata.MD) *status.Status {
+	vs := md.Get("grpc-status")
+	if len(vs) == 0 {
+		return nil
+	}
+	code, err := strconv.Atoi(vs[0])
+	if err != nil {
+		return status.Newf(codes.Internal, "malformed grpc-status: %q", vs[0])
+	}
+	if details := md.Get("grpc-status-details-bin"); len(details) > 0 {
+		var sp spb.Status
+		if err := proto.Unmarshal([]byte(details[0]), &sp); err == nil && sp.GetCode() == int32(code) {
+			return status.FromProto(&sp)
+		}
+	}
+	msg := ""
+	if vs := md.Get("grpc-message"); len(vs) > 0 {
+		msg = vs[0]
+		if unescaped, err := url.PathUnescape(msg); err == nil {
+			msg = unescaped
+		}
+	}
+	return status.New(codes.Code(code), msg)
+}
+
+// stripStatusMetadata removes the keys used to carry the status from md.
+func stripStatusMetadata(md metadata.MD) metadata.MD {
+	for _, k := range []string{"grpc-status", "grpc-message", "grpc-status-details-bin"} {
+		delete(md, k)
+	}
+	return md
+}
+
+// httpStatusCode maps an HTTP status of a response lacking a gRPC status to
+// a code, as described in the gRPC HTTP/2 protocol.
+func httpStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// writeFrame writes data prefixed with a flag byte and its length, the
+// framing shared by gRPC, gRPC-Web and Connect streaming.
+func writeFrame(w io.Writer, flags byte, data []byte) error {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// readFrame reads a frame written by writeFrame. It returns io.EOF if r ends
+// before the frame starts.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:1]); err != nil {
+		return 0, nil, err
+	}
+	if _, err := io.ReadFull(r, prefix[1:]); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return prefix[0], data, nil
+}
+
+func (t *grpcWebTransport) Invoke(ctx context.Context, mdesc *desc.MethodDescriptor, req proto.Message, onResponse func(proto.Message) error) (metadata.MD, metadata.MD, error) {
+	if mdesc.IsClientStreaming() {
+		return nil, nil, fmt.Errorf("client streaming method is not supported by gRPC-Web")
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal %v", err)
+	}
+	body := &bytes.Buffer{}
+	writeFrame(body, 0, data)
+
+	u := t.baseURL + "/" + mdesc.GetService().GetFullyQualifiedName() + "/" + mdesc.GetName()
+	httpReq, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	setMetadataHeaders(ctx, httpReq.Header)
+	if ms, ok := timeoutMillis(ctx); ok {
+		httpReq.Header.Set("grpc-timeout", ms+"m")
+	}
+	httpReq.Header.Set("Content-Type", grpcWebContentType)
+	httpReq.Header.Set("Accept", grpcWebContentType)
+	httpReq.Header.Set("X-Grpc-Web", "1")
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unavailable, err.Error())
+	}
+	defer resp.Body.Close()
+
+	header := headerMetadata(resp.Header)
+	if st := statusFromMetadata(header); st != nil {
+		// trailers-only response
+		return nil, stripStatusMetadata(header), st.Err()
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return header, nil, status.Errorf(httpStatusCode(resp.StatusCode), "unexpected HTTP status %s: %s", resp.Status, b)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for {
+		flags, data, err := readFrame(r)
+		if err == io.EOF {
+			return header, nil, status.Error(codes.Internal, "gRPC-Web response ended without trailers")
+		}
+		if err != nil {
+			return header, nil, status.Errorf(codes.Internal, "failed to read gRPC-Web response: %v", err)
+		}
+		if flags&grpcWebTrailerFlag != 0 {
+			trailer, err := parseGRPCWebTrailer(data)
+			if err != nil {
+				return header, nil, status.Error(codes.Internal, err.Error())
+			}
+			st := statusFromMetadata(trailer)
+			if st == nil {
+				return header, trailer, status.Error(codes.Internal, "gRPC-Web trailers without grpc-status")
+			}
+			return header, stripStatusMetadata(trailer), st.Err()
+		}
+		if flags != 0 {
+			return header, nil, status.Errorf(codes.Internal, "unsupported gRPC-Web frame flags: %#x", flags)
+		}
+
+		msg := dynamic.NewMessage(mdesc.GetOutputType())
+		if err := msg.Unmarshal(data); err != nil {
+			return header, nil, status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+		}
+		if err := onResponse(msg); err != nil {
+			return header, nil, err
+		}
+	}
+}
+
+// parseGRPCWebTrailer parses the HTTP/1 style header block of a trailer
+// frame.
+func parseGRPCWebTrailer(data []byte) (metadata.MD, error) {
+	h := http.Header{}
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed gRPC-Web trailer: %q", line)
+		}
+		h.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	return headerMetadata(h), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kazegusuri/grpcurl/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startGRPCWebServer(t *testing.T) string {
+	s := test.NewServer()
+	srv := httptest.NewServer(test.NewGRPCWebHandler(s))
+	t.Cleanup(func() {
+		srv.Close()
+		s.Stop()
+	})
+	return srv.URL
+}
+
+func testGRPCWebCall(t *testing.T, method, msg string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(msg), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--protocol", "grpc-web",
+		"-I", "internal/testdata", "--proto", "echo_service.proto",
+		startGRPCWebServer(t), method})
+	return buf, cmd.Command().Execute()
+}
+
+func TestGRPCWebUnary(t *testing.T) {
+	buf, err := testGRPCWebCall(t, "grpcurl.test.Echo.Echo", `{"value": "hello"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", buf.String())
+}
+
+func TestGRPCWebServerStreaming(t *testing.T) {
+	buf, err := testGRPCWebCall(t, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "hello"}`)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 10)
+	for _, line := range lines {
+		assert.Equal(t, `{"value":"hello","error_code":0}`, line)
+	}
+}
+
+func TestGRPCWebError(t *testing.T) {
+	buf, err := testGRPCWebCall(t, "grpcurl.test.Echo.Echo", `{"value": "oops", "error_code": 5}`)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"code\":5,\"message\":\"error msg: oops\",\"details\":[]}\n", buf.String())
+}
+
+func TestGRPCWebRequiresDescriptors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{}`), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--protocol", "grpc-web", addr, "grpcurl.test.Echo.Echo"})
+	err := cmd.Command().Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--proto or --protoset is required")
+}
+
+func TestGRPCWebTLS(t *testing.T) {
+	s := test.NewServer()
+	srv := httptest.NewTLSServer(test.NewGRPCWebHandler(s))
+	t.Cleanup(func() {
+		srv.Close()
+		s.Stop()
+	})
+	cacert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(cacert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	call := func(args ...string) (*bytes.Buffer, error) {
+		buf := &bytes.Buffer{}
+		cmd := NewRootCommand(strings.NewReader(`{"value": "hello"}`), buf)
+		cmd.Command().SetArgs(append(append([]string{"call", "--protocol", "grpc-web",
+			"-I", "internal/testdata", "--proto", "echo_service.proto"}, args...),
+			strings.TrimPrefix(srv.URL, "https://"), "grpcurl.test.Echo.Echo"))
+		return buf, cmd.Command().Execute()
+	}
+
+	buf, err := call("--cacert", cacert)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", buf.String())
+
+	// unknown authority without --cacert
+	buf, err = call()
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"code":14`)
+	assert.Contains(t, buf.String(), "x509: certificate signed by unknown authority")
+}
+
+func TestGRPCWebHTTP1(t *testing.T) {
+	s := test.NewServer()
+	handler := test.NewGRPCWebHandler(s)
+	var proto string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		handler.ServeHTTP(w, r)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(func() {
+		srv.Close()
+		s.Stop()
+	})
+	cacert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(cacert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "hello"}`), buf)
+	cmd.Command().SetArgs([]string{"--cacert", cacert, "call", "--protocol", "grpc-web",
+		"-I", "internal/testdata", "--proto", "echo_service.proto",
+		strings.TrimPrefix(srv.URL, "https://"), "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", buf.String())
+	// not upgraded to HTTP/2 though the server offers it
+	assert.Equal(t, "HTTP/1.1", proto)
+}
@@ -3,8 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/kazegusuri/grpcurl/internal"
 )
@@ -15,6 +19,17 @@ var (
 )
 
 func TestMain(m *testing.M) {
+	// keep the address history and caches written by tests out of the
+	// user's home
+	home, err := ioutil.TempDir("", "grpcurl-test-home")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create home: %v", err)
+		os.Exit(1)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
 	ctx := context.Background()
 	go func() {
 		if err := test.RunServer(ctx, testPort); err != nil {
@@ -22,5 +37,19 @@ func TestMain(m *testing.M) {
 			os.Exit(1)
 		}
 	}()
-	os.Exit(m.Run())
+	waitServer(addr)
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+func waitServer(addr string) {
+	for i := 0; i < 50; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
 }
@@ -0,0 +1,283 @@
+package test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var connectCodeNames = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+var connectHTTPStatus = map[codes.Code]int{
+	codes.Canceled:           499,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+type connectError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message,omitempty"`
+	Details []connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type connectEndStream struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// NewConnectHandler serves Connect unary and server streaming requests by
+// translating them for s. Responses are buffered until the call completes.
+func NewConnectHandler(s *grpc.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct := r.Header.Get("Content-Type")
+		streaming := strings.HasPrefix(ct, "application/connect+")
+		codec := strings.TrimPrefix(strings.TrimPrefix(ct, "application/connect+"), "application/")
+		if r.Method != http.MethodPost || (codec != "proto" && codec != "json") {
+			http.Error(w, "not a Connect request", http.StatusUnsupportedMediaType)
+			return
+		}
+		input, output, err := connectMethodTypes(r.URL.Path)
+		if err != nil {
+			writeConnectError(w, &connectError{Code: "unimplemented", Message: err.Error()}, http.StatusNotFound)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var msgs [][]byte
+		if streaming {
+			msgs, err = readConnectFrames(body)
+		} else {
+			msgs = [][]byte{body}
+		}
+		grpcBody := &bytes.Buffer{}
+		for _, msg := range msgs {
+			if codec == "json" && err == nil {
+				msg, err = convertMessage(input, msg, protojson.Unmarshal, proto.Marshal)
+			}
+			writeFrame(grpcBody, 0, msg)
+		}
+		if err != nil {
+			writeConnectError(w, &connectError{Code: "invalid_argument", Message: err.Error()}, http.StatusBadRequest)
+			return
+		}
+
+		req := r.Clone(r.Context())
+		req.ProtoMajor, req.ProtoMinor = 2, 0
+		req.Body = ioutil.NopCloser(grpcBody)
+		req.ContentLength = int64(grpcBody.Len())
+		req.Header.Set("Content-Type", "application/grpc+proto")
+		req.Header.Del("Connect-Protocol-Version")
+		if ms := req.Header.Get("Connect-Timeout-Ms"); ms != "" {
+			req.Header.Set("Grpc-Timeout", ms+"m")
+			req.Header.Del("Connect-Timeout-Ms")
+		}
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		res := rec.Result()
+		data, _ := ioutil.ReadAll(res.Body)
+		frames, err := readConnectFrames(data)
+		if err != nil {
+			writeConnectError(w, &connectError{Code: "internal", Message: err.Error()}, http.StatusInternalServerError)
+			return
+		}
+
+		h := w.Header()
+		for k, vs := range res.Header {
+			if k == "Content-Type" || k == "Trailer" {
+				continue
+			}
+			h[k] = vs
+		}
+		h.Set("Content-Type", ct)
+		code, cerr := connectStatus(res.Trailer)
+		trailer := map[string][]string{}
+		for k, vs := range res.Trailer {
+			if !strings.HasPrefix(k, "Grpc-") {
+				trailer[strings.ToLower(k)] = vs
+			}
+		}
+
+		if !streaming {
+			for k, vs := range trailer {
+				h["Trailer-"+k] = vs
+			}
+			if cerr != nil {
+				status, ok := connectHTTPStatus[code]
+				if !ok {
+					status = http.StatusInternalServerError
+				}
+				writeConnectError(w, cerr, status)
+				return
+			}
+			if len(frames) != 1 {
+				writeConnectError(w, &connectError{Code: "internal", Message: "unary call without response"}, http.StatusInternalServerError)
+				return
+			}
+			if codec == "json" {
+				frames[0], _ = convertMessage(output, frames[0], proto.Unmarshal, protojson.Marshal)
+			}
+			w.Write(frames[0])
+			return
+		}
+
+		for _, frame := range frames {
+			if codec == "json" {
+				frame, _ = convertMessage(output, frame, proto.Unmarshal, protojson.Marshal)
+			}
+			writeFrame(w, 0, frame)
+		}
+		end, _ := json.Marshal(&connectEndStream{Error: cerr, Metadata: trailer})
+		writeFrame(w, 0x02, end)
+	})
+}
+
+// connectMethodTypes returns the input and output types of the method named
+// by a path of the form /package.Service/Method.
+func connectMethodTypes(path string) (protoreflect.MessageType, protoreflect.MessageType, error) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("invalid method path: %s", path)
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(parts[0]))
+	if err != nil {
+		return nil, nil, err
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("not a service: %s", parts[0])
+	}
+	md := sd.Methods().ByName(protoreflect.Name(parts[1]))
+	if md == nil {
+		return nil, nil, fmt.Errorf("method not found: %s", path)
+	}
+	input, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return nil, nil, err
+	}
+	return input, output, nil
+}
+
+// convertMessage re-encodes data, a message of type mt.
+func convertMessage(mt protoreflect.MessageType, data []byte,
+	unmarshal func([]byte, proto.Message) error, marshal func(proto.Message) ([]byte, error)) ([]byte, error) {
+	msg := mt.New().Interface()
+	if err := unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return marshal(msg)
+}
+
+// connectStatus converts the status trailers of a gRPC response into an
+// error, or nil for OK.
+func connectStatus(trailer http.Header) (codes.Code, *connectError) {
+	code, _ := strconv.Atoi(trailer.Get("Grpc-Status"))
+	if codes.Code(code) == codes.OK {
+		return codes.OK, nil
+	}
+	e := &connectError{Code: connectCodeNames[codes.Code(code)]}
+	if e.Code == "" {
+		e.Code = "unknown"
+	}
+	e.Message, _ = url.PathUnescape(trailer.Get("Grpc-Message"))
+	if b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(trailer.Get("Grpc-Status-Details-Bin"), "=")); err == nil && len(b) > 0 {
+		var sp spb.Status
+		if err := proto.Unmarshal(b, &sp); err == nil {
+			e.Message = sp.GetMessage()
+			for _, d := range sp.GetDetails() {
+				e.Details = append(e.Details, connectErrorDetail{
+					Type:  strings.TrimPrefix(d.GetTypeUrl(), "type.googleapis.com/"),
+					Value: base64.RawStdEncoding.EncodeToString(d.GetValue()),
+				})
+			}
+		}
+	}
+	return codes.Code(code), e
+}
+
+func writeConnectError(w http.ResponseWriter, e *connectError, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
+
+func writeFrame(w io.Writer, flags byte, data []byte) {
+	var prefix [5]byte
+	prefix[0] = flags
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	w.Write(prefix[:])
+	w.Write(data)
+}
+
+func readConnectFrames(data []byte) ([][]byte, error) {
+	var frames [][]byte
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, fmt.Errorf("truncated frame")
+		}
+		n := int(binary.BigEndian.Uint32(data[1:5]))
+		if len(data) < 5+n {
+			return nil, fmt.Errorf("truncated frame")
+		}
+		frames = append(frames, data[5:5+n])
+		data = data[5+n:]
+	}
+	return frames, nil
+}
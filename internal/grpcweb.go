@@ -0,0 +1,93 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+// NewGRPCWebHandler serves gRPC-Web requests over HTTP/1.1 by translating
+// them for s, the way a gRPC-Web proxy does.
+func NewGRPCWebHandler(s *grpc.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web") {
+			http.Error(w, "not a gRPC-Web request", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		req := r.Clone(r.Context())
+		req.ProtoMajor, req.ProtoMinor = 2, 0
+		req.Header.Set("Content-Type", "application/grpc+proto")
+		req.Header.Del("X-Grpc-Web")
+
+		gw := &grpcWebResponseWriter{w: w, header: http.Header{}}
+		s.ServeHTTP(gw, req)
+		gw.writeTrailer()
+	})
+}
+
+// statusTrailers are the trailers declared by a gRPC server handler.
+var statusTrailers = map[string]bool{
+	"Grpc-Status":             true,
+	"Grpc-Message":            true,
+	"Grpc-Status-Details-Bin": true,
+}
+
+// grpcWebResponseWriter passes the message frames written by a gRPC server
+// through and sends its trailers as a trailer frame at the end of the body.
+type grpcWebResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	wroteHeader bool
+}
+
+func (gw *grpcWebResponseWriter) Header() http.Header {
+	return gw.header
+}
+
+func (gw *grpcWebResponseWriter) WriteHeader(code int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	h := gw.w.Header()
+	for k, vs := range gw.header {
+		if k == "Trailer" {
+			continue
+		}
+		h[k] = vs
+	}
+	h.Set("Content-Type", "application/grpc-web+proto")
+	gw.w.WriteHeader(code)
+}
+
+func (gw *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	gw.WriteHeader(http.StatusOK)
+	return gw.w.Write(b)
+}
+
+func (gw *grpcWebResponseWriter) Flush() {
+	gw.WriteHeader(http.StatusOK)
+	gw.w.(http.Flusher).Flush()
+}
+
+func (gw *grpcWebResponseWriter) writeTrailer() {
+	gw.WriteHeader(http.StatusOK)
+
+	var b strings.Builder
+	for k, vs := range gw.header {
+		name := strings.TrimPrefix(k, http2.TrailerPrefix)
+		if name == k && !statusTrailers[k] {
+			continue
+		}
+		for _, v := range vs {
+			fmt.Fprintf(&b, "%s: %s\r\n", strings.ToLower(name), v)
+		}
+	}
+
+	writeFrame(gw.w, 0x80, []byte(b.String()))
+	gw.w.(http.Flusher).Flush()
+}
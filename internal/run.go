@@ -11,6 +11,17 @@ import (
 	"google.golang.org/grpc/reflection"
 )
 
+// NewServer returns a gRPC server with the test services and reflection
+// registered.
+func NewServer() *grpc.Server {
+	s := grpc.NewServer()
+	pb.RegisterEchoServer(s, NewEchoService())
+	pbv2.RegisterEchoServer(s, NewEchoServiceV2())
+	pb.RegisterEverythingServer(s, NewEverythingService())
+	reflection.Register(s)
+	return s
+}
+
 func RunServer(ctx context.Context, port int) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -19,15 +30,10 @@ func RunServer(ctx context.Context, port int) error {
 	if err != nil {
 		return fmt.Errorf("failed to list: %v", err)
 	}
-	s := grpc.NewServer()
+	s := NewServer()
 	defer s.Stop()
 
 	go func() {
-		pb.RegisterEchoServer(s, NewEchoService())
-		pbv2.RegisterEchoServer(s, NewEchoServiceV2())
-		pb.RegisterEverythingServer(s, NewEverythingService())
-		reflection.Register(s)
-
 		s.Serve(l)
 		cancel()
 	}()
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInvoker calls unary methods with JSON requests and responses, resolving
+// methods from a DescriptorSource. It is shared by the commands running calls
+// from files (batch, replay, test and diff), and calls over the same
+// CallTransport as call.
+type UnaryInvoker struct {
+	source      DescriptorSource
+	transport   CallTransport
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func NewUnaryInvoker(source DescriptorSource, conn *grpc.ClientConn) *UnaryInvoker {
+	return &UnaryInvoker{
+		source:      source,
+		transport:   NewGRPCTransport(conn),
+		marshaler:   newJSONMarshaler(),
+		unmarshaler: newJSONUnmarshaler(),
+	}
+}
+
+// UnaryResult is the outcome of a call. Response is nil unless Status is OK.
+type UnaryResult struct {
+	Response json.RawMessage
+	Status   *status.Status
+	Headers  metadata.MD
+	Trailers metadata.MD
+}
+
+// StreamResult is the outcome of a unary or server streaming call. Responses
+// are those received before Status, even if it is not OK.
+type StreamResult struct {
+	Responses []json.RawMessage
+	Status    *status.Status
+	Headers   metadata.MD
+	Trailers  metadata.MD
+}
+
+// Invoke calls the method with body as request. A non-OK status is reported
+// in the result; errors are returned only when the call could not be made.
+func (i *UnaryInvoker) Invoke(ctx context.Context, fullMethodName string, md metadata.MD, body []byte) (*UnaryResult, error) {
+	out, err := i.invoke(ctx, fullMethodName, md, body, false)
+	if err != nil {
+		return nil, err
+	}
+	res := &UnaryResult{
+		Status:   out.Status,
+		Headers:  out.Headers,
+		Trailers: out.Trailers,
+	}
+	if len(out.Responses) > 0 {
+		res.Response = out.Responses[0]
+	}
+	return res, nil
+}
+
+// InvokeStream calls the method, which may be server streaming, as Invoke,
+// collecting every response.
+func (i *UnaryInvoker) InvokeStream(ctx context.Context, fullMethodName string, md metadata.MD, body []byte) (*StreamResult, error) {
+	return i.invoke(ctx, fullMethodName, md, body, true)
+}
+
+func (i *UnaryInvoker) invoke(ctx context.Context, fullMethodName string, md metadata.MD, body []byte, serverStreaming bool) (*StreamResult, error) {
+	mdesc, err := resolveMethod(i.source, fullMethodName)
+	if err != nil {
+		return nil, err
+	}
+	if mdesc.IsClientStreaming() || (mdesc.IsServerStreaming() && !serverStreaming) {
+		return nil, fmt.Errorf("streaming method is not supported")
+	}
+
+	msg := dynamic.NewMessage(mdesc.GetInputType())
+	if len(body) > 0 {
+		if err := msg.UnmarshalJSONPB(i.unmarshaler, body); err != nil {
+			return nil, fmt.Errorf("unmarshal %v", err)
+		}
+	}
+
+	res := &StreamResult{
+		Status: status.New(codes.OK, ""),
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	var merr error
+	res.Headers, res.Trailers, err = i.transport.Invoke(ctx, mdesc, msg, func(resp proto.Message) error {
+		respJSON, err := i.marshaler.MarshalToString(resp)
+		if err != nil {
+			merr = fmt.Errorf("marshal %v", err)
+			return merr
+		}
+		res.Responses = append(res.Responses, json.RawMessage(respJSON))
+		return nil
+	})
+	if merr != nil {
+		return nil, merr
+	}
+	if err != nil {
+		st, ok := status.FromError(err)
+		if !ok {
+			return nil, fmt.Errorf("unknown error: %v", err)
+		}
+		res.Status = st
+	}
+	return res, nil
+}
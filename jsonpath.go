@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is either an object key, an array index or a wildcard.
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the subset of JSONPath made of member, index and
+// wildcard accessors, such as $.items[0].name, $["key with dots"] or
+// $.items[*].id. The leading $ is optional.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var steps []jsonPathStep
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			n := strings.IndexAny(p, ".[")
+			if n < 0 {
+				n = len(p)
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			if p[:n] == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{key: p[:n]})
+			}
+			p = p[n:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", path)
+			}
+			inner := p[1:end]
+			p = p[end+1:]
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			if inner == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+				continue
+			}
+			i, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", path, inner)
+			}
+			steps = append(steps, jsonPathStep{index: i, isIndex: true})
+		default:
+			// allow a bare leading key like "a.b"
+			if len(steps) > 0 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			p = "." + p
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath evaluates path against v, a value decoded by encoding/json.
+func lookupJSONPath(v interface{}, path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range steps {
+		if s.wildcard {
+			return nil, fmt.Errorf("%s: wildcards are not supported", path)
+		}
+		if s.isIndex {
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: not an array", path)
+			}
+			i := s.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i < 0 || i >= len(a) {
+				return nil, fmt.Errorf("%s: index %d out of range", path, s.index)
+			}
+			v = a[i]
+			continue
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: not an object", path)
+		}
+		v, ok = m[s.key]
+		if !ok {
+			return nil, fmt.Errorf("%s: no such field %q", path, s.key)
+		}
+	}
+	return v, nil
+}
+
+// selectJSONPath evaluates path against v like lookupJSONPath, except that
+// wildcards match every element of arrays and every member of objects, in key
+// order, so that it returns all the values matched. Below a wildcard, values
+// lacking a step are skipped rather than failing.
+func selectJSONPath(v interface{}, path string) ([]interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	hasWildcard := false
+	for _, s := range steps {
+		hasWildcard = hasWildcard || s.wildcard
+	}
+	if !hasWildcard {
+		v, err := lookupJSONPath(v, path)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}
+
+	values := []interface{}{v}
+	for _, s := range steps {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case []interface{}:
+				switch {
+				case s.wildcard:
+					next = append(next, v...)
+				case s.isIndex:
+					i := s.index
+					if i < 0 {
+						i += len(v)
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			case map[string]interface{}:
+				switch {
+				case s.wildcard:
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				case !s.isIndex:
+					if e, ok := v[s.key]; ok {
+						next = append(next, e)
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
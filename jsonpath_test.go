@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":{"b":[{"c":1},{"c":2}]},"d.e":"x"}`), &v))
+
+	tests := []struct {
+		path     string
+		expected interface{}
+	}{
+		{"$", v},
+		{"$.a.b[0].c", 1.0},
+		{"a.b[-1].c", 2.0},
+		{`$["d.e"]`, "x"},
+		{"$.a['b'][1]", map[string]interface{}{"c": 2.0}},
+	}
+	for _, tt := range tests {
+		actual, err := lookupJSONPath(v, tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.expected, actual, tt.path)
+	}
+
+	for _, path := range []string{"$.x", "$.a.b[2]", "$.a[0]", "$.a.b.c", "$.a[", "$..a"} {
+		_, err := lookupJSONPath(v, path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestSelectJSONPath(t *testing.T) {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":{"b":[{"c":1},{"c":2},{"d":3}]},"m":{"y":"2","x":"1"}}`), &v))
+
+	tests := []struct {
+		path     string
+		expected []interface{}
+	}{
+		{"$.a.b[*].c", []interface{}{1.0, 2.0}},
+		{"$.a.b[*]['d']", []interface{}{3.0}},
+		{"$.m.*", []interface{}{"1", "2"}},
+		{"$.*.b[-1].d", []interface{}{3.0}},
+		{"$.a.b[0].c", []interface{}{1.0}},
+	}
+	for _, tt := range tests {
+		actual, err := selectJSONPath(v, tt.path)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.expected, actual, tt.path)
+	}
+
+	_, err := selectJSONPath(v, "$.a.c")
+	assert.Error(t, err)
+	_, err = lookupJSONPath(v, "$.a.b[*].c")
+	assert.Error(t, err)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"strings"
+
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+)
+
+// jsonSchema is a JSON Schema, or an OpenAPI schema object.
+type jsonSchema map[string]interface{}
+
+// jsonSchemaBuilder derives schemas of the proto3 JSON mapping from
+// descriptors. Messages and enums are defined once in defs and referenced
+// as refPrefix followed by their fully-qualified name.
+type jsonSchemaBuilder struct {
+	refPrefix string
+	origName  bool
+	openAPI   bool
+	defs      map[string]jsonSchema
+}
+
+func newJSONSchemaBuilder(refPrefix string, origName, openAPI bool) *jsonSchemaBuilder {
+	return &jsonSchemaBuilder{
+		refPrefix: refPrefix,
+		origName:  origName,
+		openAPI:   openAPI,
+		defs:      map[string]jsonSchema{},
+	}
+}
+
+// fieldName returns the JSON name of fd: the name in the proto source with
+// origName, else its lowerCamelCase JSON name.
+func (b *jsonSchemaBuilder) fieldName(fd *desc.FieldDescriptor) string {
+	if b.origName {
+		return fd.GetName()
+	}
+	return fd.GetJSONName()
+}
+
+func (b *jsonSchemaBuilder) ref(name string) jsonSchema {
+	return jsonSchema{"$ref": b.refPrefix + name}
+}
+
+// messageSchema returns the schema of md: inline for well-known types, else
+// a reference to its definition.
+func (b *jsonSchemaBuilder) messageSchema(md *desc.MessageDescriptor) jsonSchema {
+	name := md.GetFullyQualifiedName()
+	if s := b.wellKnownSchema(name); s != nil {
+		return s
+	}
+	if _, ok := b.defs[name]; !ok {
+		// defined before its fields so that recursive messages terminate
+		b.defs[name] = nil
+		b.defs[name] = b.messageDefinition(md)
+	}
+	return b.ref(name)
+}
+
+func (b *jsonSchemaBuilder) messageDefinition(md *desc.MessageDescriptor) jsonSchema {
+	props := jsonSchema{}
+	for _, fd := range md.GetFields() {
+		props[b.fieldName(fd)] = b.fieldSchema(fd)
+	}
+	s := jsonSchema{
+		"type":       "object",
+		"properties": props,
+	}
+
+	// at most one field of each oneof may be set
+	var oneofs []jsonSchema
+	for _, od := range md.GetOneOfs() {
+		var present []jsonSchema
+		for _, fd := range od.GetChoices() {
+			present = append(present, jsonSchema{"required": []string{b.fieldName(fd)}})
+		}
+		choices := append([]jsonSchema{}, present...)
+		choices = append(choices, jsonSchema{"not": jsonSchema{"anyOf": present}})
+		oneofs = append(oneofs, jsonSchema{"oneOf": choices})
+	}
+	switch len(oneofs) {
+	case 0:
+	case 1:
+		s["oneOf"] = oneofs[0]["oneOf"]
+	default:
+		s["allOf"] = oneofs
+	}
+
+	addDescription(s, md.GetSourceInfo())
+	return s
+}
+
+func (b *jsonSchemaBuilder) fieldSchema(fd *desc.FieldDescriptor) jsonSchema {
+	var s jsonSchema
+	switch {
+	case fd.IsMap():
+		s = jsonSchema{
+			"type":                 "object",
+			"additionalProperties": b.singularSchema(fd.GetMapValueType()),
+		}
+	case fd.IsRepeated():
+		s = jsonSchema{
+			"type":  "array",
+			"items": b.singularSchema(fd),
+		}
+	default:
+		s = b.singularSchema(fd)
+	}
+	addDescription(s, fd.GetSourceInfo())
+	return s
+}
+
+func (b *jsonSchemaBuilder) singularSchema(fd *desc.FieldDescriptor) jsonSchema {
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return jsonSchema{"type": "number", "format": "double"}
+	case dpb.FieldDescriptorProto_TYPE_FLOAT:
+		return jsonSchema{"type": "number", "format": "float"}
+	case dpb.FieldDescriptorProto_TYPE_INT32, dpb.FieldDescriptorProto_TYPE_SINT32, dpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return jsonSchema{"type": "integer", "format": "int32"}
+	case dpb.FieldDescriptorProto_TYPE_UINT32, dpb.FieldDescriptorProto_TYPE_FIXED32:
+		return jsonSchema{"type": "integer", "format": "uint32", "minimum": 0}
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		// 64-bit integers are strings in the JSON mapping
+		return jsonSchema{"type": "string", "format": "int64"}
+	case dpb.FieldDescriptorProto_TYPE_UINT64, dpb.FieldDescriptorProto_TYPE_FIXED64:
+		return jsonSchema{"type": "string", "format": "uint64"}
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return jsonSchema{"type": "boolean"}
+	case dpb.FieldDescriptorProto_TYPE_STRING:
+		return jsonSchema{"type": "string"}
+	case dpb.FieldDescriptorProto_TYPE_BYTES:
+		return jsonSchema{"type": "string", "format": "byte"}
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		return b.enumSchema(fd.GetEnumType())
+	default:
+		return b.messageSchema(fd.GetMessageType())
+	}
+}
+
+// enumSchema returns a reference to the definition of ed, a string of one
+// of its value names.
+func (b *jsonSchemaBuilder) enumSchema(ed *desc.EnumDescriptor) jsonSchema {
+	name := ed.GetFullyQualifiedName()
+	if name == "google.protobuf.NullValue" {
+		return b.nullSchema()
+	}
+	if _, ok := b.defs[name]; !ok {
+		var values []string
+		for _, vd := range ed.GetValues() {
+			values = append(values, vd.GetName())
+		}
+		s := jsonSchema{
+			"type": "string",
+			"enum": values,
+		}
+		addDescription(s, ed.GetSourceInfo())
+		b.defs[name] = s
+	}
+	return b.ref(name)
+}
+
+func (b *jsonSchemaBuilder) nullSchema() jsonSchema {
+	if b.openAPI {
+		return jsonSchema{"nullable": true}
+	}
+	return jsonSchema{"type": "null"}
+}
+
+// wellKnownSchema returns the schema of a well-known type with a special
+// JSON representation, or nil.
+func (b *jsonSchemaBuilder) wellKnownSchema(name string) jsonSchema {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return jsonSchema{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return jsonSchema{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}
+	case "google.protobuf.FieldMask":
+		return jsonSchema{"type": "string"}
+	case "google.protobuf.Struct":
+		return jsonSchema{"type": "object"}
+	case "google.protobuf.Value":
+		return jsonSchema{}
+	case "google.protobuf.ListValue":
+		return jsonSchema{"type": "array", "items": jsonSchema{}}
+	case "google.protobuf.Empty":
+		return jsonSchema{"type": "object"}
+	case "google.protobuf.Any":
+		return jsonSchema{
+			"type":       "object",
+			"properties": jsonSchema{"@type": jsonSchema{"type": "string"}},
+			"required":   []string{"@type"},
+		}
+	case "google.protobuf.DoubleValue":
+		return jsonSchema{"type": "number", "format": "double"}
+	case "google.protobuf.FloatValue":
+		return jsonSchema{"type": "number", "format": "float"}
+	case "google.protobuf.Int64Value":
+		return jsonSchema{"type": "string", "format": "int64"}
+	case "google.protobuf.UInt64Value":
+		return jsonSchema{"type": "string", "format": "uint64"}
+	case "google.protobuf.Int32Value":
+		return jsonSchema{"type": "integer", "format": "int32"}
+	case "google.protobuf.UInt32Value":
+		return jsonSchema{"type": "integer", "format": "uint32", "minimum": 0}
+	case "google.protobuf.BoolValue":
+		return jsonSchema{"type": "boolean"}
+	case "google.protobuf.StringValue":
+		return jsonSchema{"type": "string"}
+	case "google.protobuf.BytesValue":
+		return jsonSchema{"type": "string", "format": "byte"}
+	}
+	return nil
+}
+
+// addDescription sets the description of s to the leading comments in
+// info, if any.
+func addDescription(s jsonSchema, info *dpb.SourceCodeInfo_Location) {
+	if c := strings.TrimSpace(info.GetLeadingComments()); c != "" {
+		s["description"] = c
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +11,7 @@ type ListServicesCommand struct {
 	cmd  *cobra.Command
 	opts *GlobalOptions
 	addr string
-	rcli *grpcreflect.Client
+	rcli DescriptorSource
 	long bool
 	full bool
 }
@@ -36,6 +35,7 @@ grpcurl ls localhost:8888 test.TestService
 		opts: opts,
 	}
 	c.cmd.RunE = c.Run
+	c.cmd.ValidArgsFunction = c.complete
 	c.cmd.Flags().BoolVarP(&c.long, "long", "l", false, "list long")
 	c.cmd.Flags().BoolVarP(&c.full, "full", "F", false, "fully qualified")
 	return c
@@ -50,22 +50,39 @@ func (c *ListServicesCommand) Run(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
 	c.addr = args[0]
-	conn, err := NewGRPCConnection(ctx, c.addr, c.opts.Insecure)
+	conn, err := NewGRPCConnection(ctx, c.addr, c.opts)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
-	c.rcli = NewServerReflectionClient(ctx, conn)
+	c.rcli, err = NewReflectionSource(ctx, conn, c.addr, c.opts)
+	if err != nil {
+		return err
+	}
 
 	if nargs == 1 {
-		return c.listServices(ctx)
+		err = c.listServices(ctx)
 	} else if len(args) == 2 {
-		return c.listMethods(ctx, args[1])
+		err = c.listMethods(ctx, args[1])
+	}
+	if err != nil {
+		return err
 	}
 
+	recordAddress(c.addr)
 	return nil
 }
 
+func (c *ListServicesCommand) complete(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeAddress(toComplete)
+	case 1:
+		return completeService(c.opts, args[0], toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
 func (c *ListServicesCommand) listServices(ctx context.Context) error {
 	svcs, err := c.rcli.ListServices()
 	if err != nil {
@@ -97,10 +114,15 @@ func (c *ListServicesCommand) listMethods(ctx context.Context, serviceName strin
 			if mdesc.IsServerStreaming() {
 				outRPCType = "streaming "
 			}
-			fmt.Fprintf(c.opts.Output, "%s(%s%s) return (%s%s)\n",
+			httpBinding := ""
+			if rule := httpRule(mdesc); rule != nil {
+				httpBinding = " [" + formatHTTPRule(rule) + "]"
+			}
+			fmt.Fprintf(c.opts.Output, "%s(%s%s) return (%s%s)%s\n",
 				mdesc.GetFullyQualifiedName(),
 				inRPCType, inType.GetFullyQualifiedName(),
-				outRPCType, outType.GetFullyQualifiedName())
+				outRPCType, outType.GetFullyQualifiedName(),
+				httpBinding)
 		} else {
 			fmt.Fprintf(c.opts.Output, "%s\n", mdesc.GetFullyQualifiedName())
 		}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protodesc"
+)
+
+// MockRule describes a canned answer for requests to Method whose JSON
+// representation contains every field in Match.
+type MockRule struct {
+	Method    string            `json:"method"`
+	Match     json.RawMessage   `json:"match,omitempty"`
+	Response  json.RawMessage   `json:"response,omitempty"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	Code      codes.Code        `json:"code,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Delay     string            `json:"delay,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Trailers  map[string]string `json:"trailers,omitempty"`
+
+	match interface{}
+	delay time.Duration
+}
+
+// LoadMockRules reads a JSON array of MockRule.
+func LoadMockRules(r io.Reader) ([]*MockRule, error) {
+	var rules []*MockRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to decode rules: %v", err)
+	}
+	for i, rule := range rules {
+		if rule.Method == "" {
+			return nil, fmt.Errorf("rule %d: method is required", i)
+		}
+		if len(rule.Match) > 0 {
+			if err := json.Unmarshal(rule.Match, &rule.match); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid match: %v", i, err)
+			}
+		}
+		if rule.Delay != "" {
+			d, err := time.ParseDuration(rule.Delay)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid delay: %v", i, err)
+			}
+			rule.delay = d
+		}
+	}
+	return rules, nil
+}
+
+// matchJSON reports whether actual contains everything in expected. Objects
+// match when each key of expected matches in actual, other values must be
+// equal.
+func matchJSON(expected, actual interface{}) bool {
+	switch e := expected.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, ev := range e {
+			av, ok := a[k]
+			if !ok || !matchJSON(ev, av) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+type MockCommand struct {
+	cmd       *cobra.Command
+	opts      *GlobalOptions
+	source    DescriptorSourceOptions
+	listen    string
+	rulesFile string
+}
+
+func NewMockCommand(opts *GlobalOptions) *MockCommand {
+	c := &MockCommand{
+		cmd: &cobra.Command{
+			Use:   "mock --proto FILE|--protoset FILE --listen ADDR",
+			Short: "Serve mock gRPC services answering from a rules file",
+			Example: `
+* mock
+grpcurl mock --proto test.proto --rules rules.json --listen :8888
+`,
+			Args:         cobra.NoArgs,
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.source.AddFlags(c.cmd.Flags())
+	c.cmd.Flags().StringVar(&c.listen, "listen", ":8888", "address to listen on")
+	c.cmd.Flags().StringVar(&c.rulesFile, "rules", "", "JSON file of mock rules")
+	return c
+}
+
+func (c *MockCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *MockCommand) Run(cmd *cobra.Command, args []string) error {
+	src, err := c.source.Load()
+	if err != nil {
+		return err
+	}
+
+	var rules []*MockRule
+	if c.rulesFile != "" {
+		f, err := os.Open(c.rulesFile)
+		if err != nil {
+			return err
+		}
+		rules, err = LoadMockRules(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	s, err := NewMockServer(src, rules, c.opts)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", c.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		s.GracefulStop()
+	}()
+
+	if c.opts.Verbose {
+		fmt.Fprintf(c.opts.Output, "listening on %s\n", l.Addr())
+	}
+	return s.Serve(l)
+}
+
+// NewMockServer returns a gRPC server serving every service in src, answering
+// from rules, with server reflection for the services registered.
+func NewMockServer(src *FileDescriptorSource, rules []*MockRule, opts *GlobalOptions) (*grpc.Server, error) {
+	m := &mockHandler{
+		rules:       rules,
+		opts:        opts,
+		marshaler:   newJSONMarshaler(),
+		unmarshaler: newJSONUnmarshaler(),
+	}
+
+	s := grpc.NewServer()
+	svcs, _ := src.ListServices()
+	for _, name := range svcs {
+		sdesc, err := src.ResolveService(name)
+		if err != nil {
+			return nil, err
+		}
+		sd := &grpc.ServiceDesc{
+			ServiceName: sdesc.GetFullyQualifiedName(),
+			HandlerType: (*interface{})(nil),
+			Metadata:    sdesc.GetFile().GetName(),
+		}
+		for _, mdesc := range sdesc.GetMethods() {
+			sd.Streams = append(sd.Streams, grpc.StreamDesc{
+				StreamName:    mdesc.GetName(),
+				Handler:       m.handler(mdesc),
+				ServerStreams: mdesc.IsServerStreaming(),
+				ClientStreams: mdesc.IsClientStreaming(),
+			})
+		}
+		s.RegisterService(sd, nil)
+	}
+
+	files, err := protodesc.NewFiles(desc.ToFileDescriptorSet(src.Files()...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build reflection registry: %v", err)
+	}
+	rpb.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{
+		Services:           s,
+		DescriptorResolver: files,
+	}))
+	return s, nil
+}
+
+type mockHandler struct {
+	mu          sync.Mutex
+	rules       []*MockRule
+	opts        *GlobalOptions
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func (m *mockHandler) handler(mdesc *desc.MethodDescriptor) grpc.StreamHandler {
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		if !mdesc.IsClientStreaming() {
+			req := dynamic.NewMessage(mdesc.GetInputType())
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return m.answer(stream, mdesc, req, nil)
+		}
+
+		// headers go once per stream, with the first answer
+		headerSent := false
+		var last *dynamic.Message
+		for {
+			req := dynamic.NewMessage(mdesc.GetInputType())
+			err := stream.RecvMsg(req)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if mdesc.IsServerStreaming() {
+				// bidi streaming answers each request as it arrives
+				if err := m.answer(stream, mdesc, req, &headerSent); err != nil {
+					return err
+				}
+				continue
+			}
+			last = req
+		}
+		if mdesc.IsServerStreaming() {
+			return nil
+		}
+		if last == nil {
+			last = dynamic.NewMessage(mdesc.GetInputType())
+		}
+		return m.answer(stream, mdesc, last, nil)
+	}
+}
+
+// answer sends the response of the rule matching req. For streams answering
+// several requests, headerSent tracks whether headers were already sent.
+func (m *mockHandler) answer(stream grpc.ServerStream, mdesc *desc.MethodDescriptor, req *dynamic.Message, headerSent *bool) error {
+	method := mdesc.GetFullyQualifiedName()
+	reqJSON, err := req.MarshalJSONPB(m.marshaler)
+	if err != nil {
+		return status.Errorf(codes.Internal, "marshal %v", err)
+	}
+	m.logf("%s %s\n", method, reqJSON)
+
+	var actual interface{}
+	if err := json.Unmarshal(reqJSON, &actual); err != nil {
+		return status.Errorf(codes.Internal, "unmarshal %v", err)
+	}
+	rule := m.findRule(method, actual)
+	if rule == nil {
+		return status.Errorf(codes.Unimplemented, "no mock rule matches %s", method)
+	}
+
+	if rule.delay > 0 {
+		select {
+		case <-time.After(rule.delay):
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+
+	if len(rule.Headers) > 0 && (headerSent == nil || !*headerSent) {
+		if err := stream.SendHeader(metadata.New(rule.Headers)); err != nil {
+			return err
+		}
+		if headerSent != nil {
+			*headerSent = true
+		}
+	}
+	if len(rule.Trailers) > 0 {
+		stream.SetTrailer(metadata.New(rule.Trailers))
+	}
+	if rule.Code != codes.OK {
+		return status.Error(rule.Code, rule.Message)
+	}
+
+	responses := rule.Responses
+	if len(rule.Response) > 0 {
+		responses = append([]json.RawMessage{rule.Response}, responses...)
+	}
+	if len(responses) == 0 {
+		responses = []json.RawMessage{json.RawMessage("{}")}
+	}
+	if !mdesc.IsServerStreaming() {
+		responses = responses[:1]
+	}
+	for _, r := range responses {
+		resp := dynamic.NewMessage(mdesc.GetOutputType())
+		if err := resp.UnmarshalJSONPB(m.unmarshaler, r); err != nil {
+			return status.Errorf(codes.Internal, "invalid mock response for %s: %v", method, err)
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockHandler) findRule(method string, req interface{}) *MockRule {
+	for _, rule := range m.rules {
+		if rule.Method == method && matchJSON(rule.match, req) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (m *mockHandler) logf(format string, args ...interface{}) {
+	if !m.opts.Verbose {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(m.opts.Output, format, args...)
+}
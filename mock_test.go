@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+const testMockRules = `[
+  {"method": "grpcurl.test.Echo.Echo", "match": {"value": "hello"}, "response": {"value": "world"}, "headers": {"x-mock": "yes"}},
+  {"method": "grpcurl.test.Echo.Echo", "code": "NOT_FOUND", "message": "no such value"},
+  {"method": "grpcurl.test.Echo.ServerStreamingEcho", "responses": [{"value": "a"}, {"value": "b"}]},
+  {"method": "grpcurl.test.Echo.BidiStreamingBulkEcho", "response": {"value": "bulk"}, "headers": {"x-mock": "bidi"}}
+]`
+
+func startMockServer(t *testing.T) string {
+	src, err := NewDescriptorSourceFromProtoFiles(nil, "internal/testdata/echo_service.proto")
+	require.NoError(t, err)
+	rules, err := LoadMockRules(strings.NewReader(testMockRules))
+	require.NoError(t, err)
+	s, err := NewMockServer(src, rules, &GlobalOptions{Output: &bytes.Buffer{}})
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func TestMockMatch(t *testing.T) {
+	mockAddr := startMockServer(t)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "hello"}`), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "-v", mockAddr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	resp := parseTestResponse(buf.String())
+	assert.Equal(t, `{"value":"world","error_code":0}`, resp.ResponseMessage)
+	assert.Contains(t, buf.String(), "x-mock: yes")
+}
+
+func TestMockStatus(t *testing.T) {
+	mockAddr := startMockServer(t)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "other"}`), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", mockAddr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, `{"code":5,"message":"no such value","details":[]}`+"\n", buf.String())
+}
+
+func TestMockReflection(t *testing.T) {
+	mockAddr := startMockServer(t)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "list_services", mockAddr, "grpcurl.test.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, "grpcurl.test.Echo.Echo\ngrpcurl.test.Echo.ClientStreamingEcho\ngrpcurl.test.Echo.ServerStreamingEcho\ngrpcurl.test.Echo.BidiStreamingBulkEcho\n", buf.String())
+}
+
+func TestMockBidiHeaders(t *testing.T) {
+	mockAddr := startMockServer(t)
+
+	src, err := NewDescriptorSourceFromProtoFiles(nil, "internal/testdata/echo_service.proto")
+	require.NoError(t, err)
+	mdesc, err := resolveMethod(src, "grpcurl.test.Echo.BidiStreamingBulkEcho")
+	require.NoError(t, err)
+	conn, err := grpc.Dial(mockAddr, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := grpcdynamic.NewStub(conn).InvokeRpcBidiStream(context.Background(), mdesc)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		req := dynamic.NewMessage(mdesc.GetInputType())
+		req.SetFieldByName("value", "x")
+		require.NoError(t, stream.SendMsg(req))
+		resp, err := stream.RecvMsg()
+		require.NoError(t, err)
+		assert.Equal(t, "bulk", resp.(*dynamic.Message).GetFieldByName("value"))
+	}
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.RecvMsg()
+	assert.Equal(t, io.EOF, err)
+	md, err := stream.Header()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bidi"}, md.Get("x-mock"))
+}
+
+func TestMatchJSON(t *testing.T) {
+	actual := map[string]interface{}{"value": "x", "nested": map[string]interface{}{"a": 1.0, "b": 2.0}}
+	assert.True(t, matchJSON(nil, actual))
+	assert.True(t, matchJSON(map[string]interface{}{"nested": map[string]interface{}{"a": 1.0}}, actual))
+	assert.False(t, matchJSON(map[string]interface{}{"value": "y"}, actual))
+	assert.False(t, matchJSON(map[string]interface{}{"missing": "x"}, actual))
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// openAPIBuilder collects the paths and schemas of an OpenAPI document.
+type openAPIBuilder struct {
+	schemas *jsonSchemaBuilder
+	paths   map[string]map[string]interface{}
+}
+
+func newOpenAPIBuilder() *openAPIBuilder {
+	b := &openAPIBuilder{
+		schemas: newJSONSchemaBuilder("#/components/schemas/", false, true),
+		paths:   map[string]map[string]interface{}{},
+	}
+	b.schemas.defs["google.rpc.Status"] = jsonSchema{
+		"type": "object",
+		"properties": jsonSchema{
+			"code":    jsonSchema{"type": "integer", "format": "int32"},
+			"message": jsonSchema{"type": "string"},
+			"details": jsonSchema{
+				"type":  "array",
+				"items": b.schemas.wellKnownSchema("google.protobuf.Any"),
+			},
+		},
+	}
+	return b
+}
+
+// addService adds the operations of the unary and server streaming methods
+// of sd. Methods without a google.api.http rule are bound to
+// POST /package.Service/Method with the whole request as body.
+func (b *openAPIBuilder) addService(sd *desc.ServiceDescriptor) {
+	for _, mdesc := range sd.GetMethods() {
+		if mdesc.IsClientStreaming() {
+			continue
+		}
+		rule := httpRule(mdesc)
+		if rule == nil {
+			rule = &annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{
+					Post: "/" + sd.GetFullyQualifiedName() + "/" + mdesc.GetName(),
+				},
+				Body: "*",
+			}
+		}
+		operationID := sd.GetName() + "_" + mdesc.GetName()
+		b.addOperation(mdesc, rule, operationID)
+		for i, binding := range rule.GetAdditionalBindings() {
+			b.addOperation(mdesc, binding, fmt.Sprintf("%s%d", operationID, i+2))
+		}
+	}
+}
+
+func (b *openAPIBuilder) addOperation(mdesc *desc.MethodDescriptor, rule *annotations.HttpRule, operationID string) {
+	method, template := httpRulePattern(rule)
+	if method == "" {
+		return
+	}
+	input := mdesc.GetInputType()
+
+	var params []interface{}
+	used := map[string]bool{}
+	path := pathVarPattern.ReplaceAllStringFunc(template, func(v string) string {
+		name := pathVarPattern.FindStringSubmatch(v)[1]
+		used[name] = true
+		schema := jsonSchema{"type": "string"}
+		if fd := findFieldByPath(input, name); fd != nil {
+			schema = b.schemas.fieldSchema(fd)
+		}
+		params = append(params, jsonSchema{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+		return "{" + name + "}"
+	})
+
+	op := jsonSchema{
+		"operationId": operationID,
+		"tags":        []string{mdesc.GetService().GetFullyQualifiedName()},
+		"responses": jsonSchema{
+			"200": jsonSchema{
+				"description": "A successful response.",
+				"content": jsonSchema{
+					"application/json": jsonSchema{"schema": b.responseSchema(mdesc, rule)},
+				},
+			},
+			"default": jsonSchema{
+				"description": "An error response.",
+				"content": jsonSchema{
+					"application/json": jsonSchema{"schema": b.schemas.ref("google.rpc.Status")},
+				},
+			},
+		},
+	}
+	addDescription(op, mdesc.GetSourceInfo())
+
+	switch body := rule.GetBody(); body {
+	case "*":
+		op["requestBody"] = requestBody(b.schemas.messageSchema(input))
+	case "":
+		params = append(params, b.queryParams(input, used)...)
+	default:
+		if fd := input.FindFieldByName(body); fd != nil {
+			op["requestBody"] = requestBody(b.schemas.fieldSchema(fd))
+		}
+		used[body] = true
+		params = append(params, b.queryParams(input, used)...)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if b.paths[path] == nil {
+		b.paths[path] = map[string]interface{}{}
+	}
+	b.paths[path][strings.ToLower(method)] = op
+}
+
+func requestBody(schema jsonSchema) jsonSchema {
+	return jsonSchema{
+		"required": true,
+		"content": jsonSchema{
+			"application/json": jsonSchema{"schema": schema},
+		},
+	}
+}
+
+// scalarJSONTypes are the well-known types whose JSON form is a scalar, so
+// that fields of them can be query parameters.
+var scalarJSONTypes = map[string]bool{
+	"google.protobuf.Timestamp":   true,
+	"google.protobuf.Duration":    true,
+	"google.protobuf.FieldMask":   true,
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+// queryParams returns the query parameters of the fields of md with scalar
+// JSON forms not bound elsewhere. They are named like path variables after
+// the fields in the proto source, which is what call --via-http sends.
+func (b *openAPIBuilder) queryParams(md *desc.MessageDescriptor, used map[string]bool) []interface{} {
+	var params []interface{}
+	for _, fd := range md.GetFields() {
+		if used[fd.GetName()] || fd.IsMap() {
+			continue
+		}
+		if mt := fd.GetMessageType(); mt != nil && !scalarJSONTypes[mt.GetFullyQualifiedName()] {
+			continue
+		}
+		params = append(params, jsonSchema{
+			"name":   fd.GetName(),
+			"in":     "query",
+			"schema": b.schemas.fieldSchema(fd),
+		})
+	}
+	return params
+}
+
+// responseSchema returns the schema of the response body: the field named by
+// response_body, and for server streaming methods the newline-delimited
+// result or error objects wrapping it.
+func (b *openAPIBuilder) responseSchema(mdesc *desc.MethodDescriptor, rule *annotations.HttpRule) jsonSchema {
+	output := mdesc.GetOutputType()
+	schema := b.schemas.messageSchema(output)
+	if f := rule.GetResponseBody(); f != "" {
+		if fd := output.FindFieldByName(f); fd != nil {
+			schema = b.schemas.fieldSchema(fd)
+		}
+	}
+	if mdesc.IsServerStreaming() {
+		schema = jsonSchema{
+			"type":        "object",
+			"description": "Stream of newline-delimited objects.",
+			"properties": jsonSchema{
+				"result": schema,
+				"error":  b.schemas.ref("google.rpc.Status"),
+			},
+		}
+	}
+	return schema
+}
+
+// findFieldByPath returns the field at the dotted path from md, or nil.
+func findFieldByPath(md *desc.MessageDescriptor, path string) *desc.FieldDescriptor {
+	var fd *desc.FieldDescriptor
+	for _, name := range strings.Split(path, ".") {
+		if md == nil {
+			return nil
+		}
+		if fd = md.FindFieldByName(name); fd == nil {
+			return nil
+		}
+		md = fd.GetMessageType()
+	}
+	return fd
+}
+
+// document returns the OpenAPI document.
+func (b *openAPIBuilder) document(title string) jsonSchema {
+	return jsonSchema{
+		"openapi": "3.0.3",
+		"info": jsonSchema{
+			"title":   title,
+			"version": "1.0",
+		},
+		"paths": b.paths,
+		"components": jsonSchema{
+			"schemas": b.schemas.defs,
+		},
+	}
+}
+
+type OpenAPICommand struct {
+	cmd         *cobra.Command
+	opts        *GlobalOptions
+	title       string
+	importPaths []string
+}
+
+func NewOpenAPICommand(opts *GlobalOptions) *OpenAPICommand {
+	c := &OpenAPICommand{
+		cmd: &cobra.Command{
+			Use:   "openapi ADDR",
+			Short: "Generate an OpenAPI document of services",
+			Long: `Generate an OpenAPI 3 document of the services of ADDR.
+
+Operations follow the google.api.http rules of the methods. Methods without
+a rule are bound to POST /package.Service/Method. ADDR is the address of a
+server supporting reflection, a protoset file or a directory of proto files.`,
+			Example: `
+* openapi
+grpcurl openapi localhost:8888 > openapi.json
+`,
+			Args:         cobra.ExactArgs(1),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.title, "title", "", "title of the document (default ADDR)")
+	c.cmd.Flags().StringArrayVarP(&c.importPaths, "import-path", "I", nil, "import path to resolve proto imports")
+	return c
+}
+
+func (c *OpenAPICommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *OpenAPICommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	src, closeSrc, err := OpenDescriptorSource(ctx, args[0], c.opts, c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+
+	svcs, err := src.ListServices()
+	if err != nil {
+		return fmt.Errorf("failed to list services: %v", err)
+	}
+	sort.Strings(svcs)
+
+	b := newOpenAPIBuilder()
+	for _, name := range svcs {
+		if strings.HasPrefix(name, "grpc.reflection.") {
+			continue
+		}
+		sd, err := src.ResolveService(name)
+		if err != nil {
+			return err
+		}
+		b.addService(sd)
+	}
+
+	title := c.title
+	if title == "" {
+		title = args[0]
+	}
+	out, err := json.MarshalIndent(b.document(title), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.opts.Output, "%s\n", out)
+	return nil
+}
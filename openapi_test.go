@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func testOpenAPI(t *testing.T, target string) map[string]interface{} {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "openapi", target})
+	require.NoError(t, cmd.Command().Execute())
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	return doc
+}
+
+// lookup returns the value at the keys of nested objects in doc.
+func lookup(t *testing.T, doc interface{}, keys ...string) interface{} {
+	for _, k := range keys {
+		m, ok := doc.(map[string]interface{})
+		require.True(t, ok, "%s: not an object", k)
+		doc, ok = m[k]
+		require.True(t, ok, "%s: missing", k)
+	}
+	return doc
+}
+
+func TestOpenAPIHTTPRule(t *testing.T) {
+	doc := testOpenAPI(t, startHTTPRuleServer(t))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	get := lookup(t, doc, "paths", "/v1/echo/{value}", "get")
+	assert.Equal(t, "Echo_Echo", lookup(t, get, "operationId"))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "value", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "error_code", "in": "query", "schema": map[string]interface{}{"type": "integer", "format": "uint32", "minimum": 0.0}},
+	}, lookup(t, get, "parameters"))
+	assert.Equal(t, "#/components/schemas/grpcurl.test.EchoMessage",
+		lookup(t, get, "responses", "200", "content", "application/json", "schema", "$ref"))
+
+	post := lookup(t, doc, "paths", "/v1/echo", "post")
+	assert.Equal(t, "Echo_Echo2", lookup(t, post, "operationId"))
+	assert.Equal(t, "#/components/schemas/grpcurl.test.EchoMessage",
+		lookup(t, post, "requestBody", "content", "application/json", "schema", "$ref"))
+
+	stream := lookup(t, doc, "paths", "/v1/stream", "post", "responses", "200", "content", "application/json", "schema")
+	assert.Equal(t, "#/components/schemas/grpcurl.test.EchoMessage", lookup(t, stream, "properties", "result", "$ref"))
+
+	// client streaming methods cannot be called over HTTP/JSON
+	assert.NotContains(t, lookup(t, doc, "paths"), "/grpcurl.test.Echo/ClientStreamingEcho")
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := testOpenAPI(t, addr)
+	schemas := lookup(t, doc, "components", "schemas")
+
+	// methods without a rule are bound to their gRPC path
+	lookup(t, doc, "paths", "/grpcurl.test.Everything/Number", "post")
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "int64"},
+		lookup(t, schemas, "grpcurl.test.NumberMessage", "properties", "int64Value"))
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"ZERO", "ONE", "TWO"}},
+		lookup(t, schemas, "grpcurl.test.NumericEnum"))
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/grpcurl.test.NumericEnum"}},
+		lookup(t, schemas, "grpcurl.test.EnumMessage", "properties", "repeatedNumericEnumValues"))
+	assert.Equal(t, map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"$ref": "#/components/schemas/grpcurl.test.NestedMessage"}},
+		lookup(t, schemas, "grpcurl.test.MapMessage", "properties", "mappedNestedValue"))
+}
+
+func TestOpenAPIQueryParams(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "list.proto"), []byte(`syntax = "proto3";
+package grpcurl.test;
+import "google/protobuf/duration.proto";
+import "google/protobuf/field_mask.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+message ListRequest {
+  string parent_id = 1;
+  google.protobuf.Timestamp updated_after = 2;
+  google.protobuf.Duration max_age = 3;
+  google.protobuf.FieldMask read_mask = 4;
+  google.protobuf.Int32Value page_size = 5;
+  ListRequest nested = 6;
+}
+service Lister {
+  rpc List(ListRequest) returns (ListRequest) {}
+}
+`), 0644))
+	fds, err := (&protoparse.Parser{ImportPaths: []string{dir}}).ParseFiles("list.proto")
+	require.NoError(t, err)
+	fdp := fds[0].AsFileDescriptorProto()
+	m := fdp.GetService()[0].GetMethod()[0]
+	m.Options = &dpb.MethodOptions{}
+	require.NoError(t, proto.SetExtension(m.Options, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/{parent_id}/items"},
+	}))
+	fd, err := desc.CreateFileDescriptor(fdp, fds[0].GetDependencies()...)
+	require.NoError(t, err)
+	b, err := proto.Marshal(desc.ToFileDescriptorSet(fd))
+	require.NoError(t, err)
+	protoset := filepath.Join(dir, "list.protoset")
+	require.NoError(t, ioutil.WriteFile(protoset, b, 0644))
+
+	doc := testOpenAPI(t, protoset)
+	get := lookup(t, doc, "paths", "/v1/{parent_id}/items", "get")
+	// named like the path variable, and without the nested message
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "parent_id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "updated_after", "in": "query", "schema": map[string]interface{}{"type": "string", "format": "date-time"}},
+		map[string]interface{}{"name": "max_age", "in": "query", "schema": map[string]interface{}{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`}},
+		map[string]interface{}{"name": "read_mask", "in": "query", "schema": map[string]interface{}{"type": "string"}},
+		map[string]interface{}{"name": "page_size", "in": "query", "schema": map[string]interface{}{"type": "integer", "format": "int32"}},
+	}, lookup(t, get, "parameters"))
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// profileTargetArg is the ADDR argument standing for the target of the
+// selected profile.
+const profileTargetArg = "@"
+
+// Config is the configuration file of named profiles.
+type Config struct {
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// Profile holds the defaults selected with --profile. Explicit flags
+// override them.
+type Profile struct {
+	Target      string      `yaml:"target"`
+	Insecure    bool        `yaml:"insecure"`
+	TLS         ProfileTLS  `yaml:"tls"`
+	Headers     []string    `yaml:"headers"`
+	Auth        ProfileAuth `yaml:"auth"`
+	ProtoSets   []string    `yaml:"protoset"`
+	ProtoFiles  []string    `yaml:"proto"`
+	ImportPaths []string    `yaml:"import_paths"`
+}
+
+type ProfileTLS struct {
+	CACert     string `yaml:"cacert"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+	ServerName string `yaml:"servername"`
+}
+
+// ProfileAuth is the credential sent in the authorization header.
+type ProfileAuth struct {
+	Token string `yaml:"token"`
+}
+
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "grpcurl", "config.yaml"), nil
+}
+
+// LoadConfig reads the configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// applyProfile applies the profile selected by o.Profile to the flags of cmd
+// not given explicitly, and replaces ADDR arguments of "@" with its target.
+func (o *GlobalOptions) applyProfile(cmd *cobra.Command, args []string) error {
+	if o.Profile == "" {
+		for _, arg := range args {
+			if arg == profileTargetArg {
+				return fmt.Errorf("%s requires --profile", profileTargetArg)
+			}
+		}
+		return nil
+	}
+
+	path := o.ConfigFile
+	if path == "" {
+		var err error
+		if path, err = defaultConfigPath(); err != nil {
+			return fmt.Errorf("failed to find config: %v", err)
+		}
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	p, ok := config.Profiles[o.Profile]
+	if !ok {
+		return fmt.Errorf("profile not found: %s", o.Profile)
+	}
+
+	// file paths are relative to the config file
+	dir := filepath.Dir(path)
+	resolve := func(paths ...string) []string {
+		var res []string
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			res = append(res, p)
+		}
+		return res
+	}
+	var insecure []string
+	if p.Insecure {
+		insecure = []string{strconv.FormatBool(p.Insecure)}
+	}
+	defaults := []struct {
+		flag   string
+		values []string
+	}{
+		{"insecure", insecure},
+		{"cacert", resolve(p.TLS.CACert)},
+		{"cert", resolve(p.TLS.Cert)},
+		{"key", resolve(p.TLS.Key)},
+		{"servername", []string{p.TLS.ServerName}},
+		{"protoset", resolve(p.ProtoSets...)},
+		{"proto", p.ProtoFiles},
+		{"import-path", resolve(p.ImportPaths...)},
+	}
+	for _, d := range defaults {
+		f := cmd.Flags().Lookup(d.flag)
+		if f == nil || f.Changed {
+			continue
+		}
+		for _, v := range d.values {
+			if v == "" {
+				continue
+			}
+			if err := cmd.Flags().Set(d.flag, v); err != nil {
+				return fmt.Errorf("profile %s: invalid %s: %v", o.Profile, d.flag, err)
+			}
+		}
+	}
+
+	o.Headers = nil
+	for _, h := range p.Headers {
+		o.Headers = append(o.Headers, os.ExpandEnv(h))
+	}
+	if token := os.ExpandEnv(p.Auth.Token); token != "" {
+		o.Headers = append(o.Headers, "authorization: Bearer "+token)
+	}
+
+	for i, arg := range args {
+		if arg != profileTargetArg {
+			continue
+		}
+		if p.Target == "" {
+			return fmt.Errorf("profile %s has no target", o.Profile)
+		}
+		// args is shared with RunE
+		args[i] = p.Target
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func writeConfig(t *testing.T, config string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(config), 0644))
+	return path
+}
+
+func testProfile(t *testing.T, input string, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(input), buf)
+	cmd.Command().SetArgs(args)
+	err := cmd.Command().Execute()
+	return buf.String(), err
+}
+
+func TestProfile(t *testing.T) {
+	os.Setenv("GRPCURL_TEST_TOKEN", "secret")
+	defer os.Unsetenv("GRPCURL_TEST_TOKEN")
+	config := writeConfig(t, `profiles:
+  local:
+    target: `+addr+`
+    insecure: true
+    headers:
+      - "x-env: test"
+      - "x-team: api"
+    auth:
+      token: ${GRPCURL_TEST_TOKEN}
+`)
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+
+	out, err := testProfile(t, `{"value": "hello"}`, "--config", config, "--profile", "local",
+		"call", "@", "grpcurl.test.Echo.Echo", "-H", "x-team: web", "--record", record)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", out)
+
+	b, err := ioutil.ReadFile(record)
+	require.NoError(t, err)
+	var e struct {
+		Metadata metadata.MD `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(b, &e))
+	assert.Equal(t, metadata.MD{
+		"x-env":         {"test"},
+		"x-team":        {"web"},
+		"authorization": {redactedValue},
+	}, e.Metadata)
+	assert.NotContains(t, string(b), "secret")
+}
+
+func TestProfileFlagsOverride(t *testing.T) {
+	protoset := filepath.Join(t.TempDir(), "api.pb")
+	_, err := testProfile(t, "", "-k", "export", addr, "--protoset", protoset)
+	require.NoError(t, err)
+	config := writeConfig(t, `profiles:
+  offline:
+    insecure: true
+    protoset: [missing.pb]
+`)
+
+	// the protoset of the profile is relative to the config file
+	_, err = testProfile(t, `{"value": "hello"}`, "--config", config, "--profile", "offline",
+		"call", addr, "grpcurl.test.Echo.Echo")
+	assert.EqualError(t, err, "failed to read protoset: open "+filepath.Join(filepath.Dir(config), "missing.pb")+": no such file or directory")
+
+	out, err := testProfile(t, `{"value": "hello"}`, "--config", config, "--profile", "offline",
+		"call", addr, "grpcurl.test.Echo.Echo", "--protoset", protoset)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"value\":\"hello\",\"error_code\":0}\n", out)
+}
+
+func TestProfileErrors(t *testing.T) {
+	config := writeConfig(t, `profiles:
+  notarget:
+    insecure: true
+`)
+	_, err := testProfile(t, "", "--config", config, "--profile", "missing", "ls", addr)
+	assert.EqualError(t, err, "profile not found: missing")
+
+	_, err = testProfile(t, "", "--config", config, "--profile", "notarget", "ls", "@")
+	assert.EqualError(t, err, "profile notarget has no target")
+
+	_, err = testProfile(t, "", "-k", "ls", "@")
+	assert.EqualError(t, err, "@ requires --profile")
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// rawFrame holds a serialized message as received on the wire.
+type rawFrame struct {
+	data []byte
+}
+
+// rawCodec passes rawFrame through without decoding it.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	f, ok := v.(*rawFrame)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return f.data, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	f, ok := v.(*rawFrame)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	f.data = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// ProxyLogEntry is a single line of the proxy log.
+type ProxyLogEntry struct {
+	Time     time.Time       `json:"time"`
+	Elapsed  string          `json:"elapsed"`
+	ID       int64           `json:"id"`
+	Method   string          `json:"method"`
+	Event    string          `json:"event"`
+	Metadata metadata.MD     `json:"metadata,omitempty"`
+	Message  json.RawMessage `json:"message,omitempty"`
+	Raw      string          `json:"raw,omitempty"`
+	Status   *ExchangeStatus `json:"status,omitempty"`
+}
+
+type ProxyCommand struct {
+	cmd      *cobra.Command
+	opts     *GlobalOptions
+	listen   string
+	upstream string
+}
+
+func NewProxyCommand(opts *GlobalOptions) *ProxyCommand {
+	c := &ProxyCommand{
+		cmd: &cobra.Command{
+			Use:   "proxy --listen ADDR --upstream ADDR",
+			Short: "Forward calls to a server, logging them as JSON",
+			Example: `
+* proxy
+grpcurl proxy --listen :9000 --upstream localhost:8888
+`,
+			Args:         cobra.NoArgs,
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.listen, "listen", ":9000", "address to listen on")
+	c.cmd.Flags().StringVar(&c.upstream, "upstream", "", "address of the server to forward to")
+	return c
+}
+
+func (c *ProxyCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ProxyCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	if c.upstream == "" {
+		return fmt.Errorf("--upstream is required")
+	}
+
+	conn, err := NewGRPCConnection(ctx, c.upstream, c.opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	src, err := NewReflectionSource(ctx, conn, c.upstream, c.opts.withoutDescriptorCache())
+	if err != nil {
+		return err
+	}
+	s := NewProxyServer(conn, src, c.opts.Output)
+
+	l, err := net.Listen("tcp", c.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		s.GracefulStop()
+	}()
+
+	return s.Serve(l)
+}
+
+// NewProxyServer returns a gRPC server forwarding every call to upstream.
+// Messages are decoded with descriptors from source, when it knows the
+// method, and logged to w.
+func NewProxyServer(upstream *grpc.ClientConn, source DescriptorSource, w io.Writer) *grpc.Server {
+	p := &proxyHandler{
+		upstream:  upstream,
+		source:    source,
+		methods:   map[string]*desc.MethodDescriptor{},
+		enc:       json.NewEncoder(w),
+		marshaler: &jsonpb.Marshaler{OrigName: true, AnyResolver: DynamicAnyResolver{}},
+	}
+	return grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(p.handle),
+	)
+}
+
+type proxyHandler struct {
+	upstream  *grpc.ClientConn
+	source    DescriptorSource
+	marshaler *jsonpb.Marshaler
+
+	mu      sync.Mutex
+	nextID  int64
+	methods map[string]*desc.MethodDescriptor
+	enc     *json.Encoder
+}
+
+// proxyCall logs the events of one forwarded call.
+type proxyCall struct {
+	p      *proxyHandler
+	id     int64
+	method string
+	mdesc  *desc.MethodDescriptor
+	start  time.Time
+}
+
+func (p *proxyHandler) handle(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "no method in stream")
+	}
+
+	p.mu.Lock()
+	p.nextID++
+	call := &proxyCall{
+		p:      p,
+		id:     p.nextID,
+		method: fullMethod,
+		start:  time.Now(),
+	}
+	p.mu.Unlock()
+	call.mdesc = p.resolve(fullMethod)
+
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	call.log(&ProxyLogEntry{Event: "start", Metadata: md})
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	// stop cancels forwarding requests and waits for a request being
+	// forwarded. The pending RecvMsg of the client stream can't be
+	// cancelled; it returns once the handler has returned, and what it
+	// receives then is dropped.
+	var fwdMu sync.Mutex
+	stopped := false
+	stop := func() {
+		cancel()
+		fwdMu.Lock()
+		defer fwdMu.Unlock()
+		stopped = true
+	}
+	defer stop()
+	ctx = metadata.NewOutgoingContext(ctx, md.Copy())
+	up, err := p.upstream.NewStream(ctx, &grpc.StreamDesc{
+		ServerStreams: true,
+		ClientStreams: true,
+	}, fullMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return call.end(err, nil)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			f := &rawFrame{}
+			if err := stream.RecvMsg(f); err != nil {
+				if err == io.EOF {
+					errc <- up.CloseSend()
+					return
+				}
+				errc <- err
+				return
+			}
+			fwdMu.Lock()
+			if stopped {
+				fwdMu.Unlock()
+				return
+			}
+			call.logMessage("request", f, true)
+			err := up.SendMsg(f)
+			fwdMu.Unlock()
+			if err != nil {
+				// the error is reported by RecvMsg on upstream
+				errc <- nil
+				return
+			}
+		}
+	}()
+
+	header, err := up.Header()
+	if err == nil {
+		call.log(&ProxyLogEntry{Event: "header", Metadata: header})
+		if err := stream.SendHeader(header); err != nil {
+			return call.end(err, nil)
+		}
+	}
+	for {
+		f := &rawFrame{}
+		if err = up.RecvMsg(f); err != nil {
+			break
+		}
+		call.logMessage("response", f, false)
+		if err := stream.SendMsg(f); err != nil {
+			return call.end(err, nil)
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if err == nil {
+		// report a failure of sending requests, without waiting for the
+		// client to half-close: a bidi server may end first, and stop
+		// releases the forwarding when the handler returns
+		select {
+		case err = <-errc:
+		default:
+		}
+	}
+	trailer := up.Trailer()
+	stream.SetTrailer(trailer)
+	return call.end(err, trailer)
+}
+
+// resolve returns the descriptor of fullMethod, or nil if source doesn't
+// know it. Lookups go over the network, so they are made without holding
+// p.mu; concurrent calls of a new method may each look it up.
+func (p *proxyHandler) resolve(fullMethod string) *desc.MethodDescriptor {
+	p.mu.Lock()
+	mdesc, ok := p.methods[fullMethod]
+	p.mu.Unlock()
+	if ok {
+		return mdesc
+	}
+	// convert /pkg.Service/Method into pkg.Service.Method
+	name := strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1)
+	mdesc, err := resolveMethod(p.source, name)
+	if err != nil {
+		mdesc = nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.methods[fullMethod] = mdesc
+	return mdesc
+}
+
+func (c *proxyCall) log(e *ProxyLogEntry) {
+	now := time.Now()
+	e.Time = now
+	e.Elapsed = now.Sub(c.start).String()
+	e.ID = c.id
+	e.Method = c.method
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	c.p.enc.Encode(e)
+}
+
+func (c *proxyCall) logMessage(event string, f *rawFrame, input bool) {
+	e := &ProxyLogEntry{Event: event}
+	if c.mdesc != nil {
+		md := c.mdesc.GetOutputType()
+		if input {
+			md = c.mdesc.GetInputType()
+		}
+		msg := dynamic.NewMessage(md)
+		if err := msg.Unmarshal(f.data); err == nil {
+			if b, err := msg.MarshalJSONPB(c.p.marshaler); err == nil {
+				e.Message = b
+			}
+		}
+	}
+	if e.Message == nil {
+		e.Raw = base64.StdEncoding.EncodeToString(f.data)
+	}
+	c.log(e)
+}
+
+func (c *proxyCall) end(err error, trailer metadata.MD) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		st = status.New(codes.Unknown, err.Error())
+	}
+	c.log(&ProxyLogEntry{Event: "end", Metadata: trailer, Status: NewExchangeStatus(st)})
+	return st.Err()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func startProxyServer(t *testing.T, log *bytes.Buffer) string {
+	ctx := context.Background()
+	conn, err := NewGRPCConnection(ctx, addr, &GlobalOptions{Insecure: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	s := NewProxyServer(conn, NewServerReflectionClient(ctx, conn, &GlobalOptions{}), log)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func parseProxyLog(t *testing.T, log *bytes.Buffer) []*ProxyLogEntry {
+	var entries []*ProxyLogEntry
+	dec := json.NewDecoder(log)
+	for dec.More() {
+		var e ProxyLogEntry
+		require.NoError(t, dec.Decode(&e))
+		entries = append(entries, &e)
+	}
+	return entries
+}
+
+func TestProxy(t *testing.T) {
+	log := &bytes.Buffer{}
+	proxyAddr := startProxyServer(t, log)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "xxx"}`), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "-H", "x-test: 1", proxyAddr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, `{"value":"xxx","error_code":0}`+"\n", buf.String())
+
+	var echoes []*ProxyLogEntry
+	for _, e := range parseProxyLog(t, log) {
+		if e.Method == "/grpcurl.test.Echo/Echo" {
+			echoes = append(echoes, e)
+		}
+	}
+	require.Len(t, echoes, 5)
+	assert.Equal(t, "start", echoes[0].Event)
+	assert.Equal(t, []string{"1"}, echoes[0].Metadata["x-test"])
+	assert.Equal(t, "request", echoes[1].Event)
+	assert.Equal(t, `{"value":"xxx"}`, string(echoes[1].Message))
+	assert.Equal(t, "header", echoes[2].Event)
+	assert.Equal(t, "response", echoes[3].Event)
+	assert.Equal(t, `{"value":"xxx"}`, string(echoes[3].Message))
+	assert.Equal(t, "end", echoes[4].Event)
+	assert.Equal(t, codes.OK, echoes[4].Status.Code)
+}
+
+func TestProxyError(t *testing.T) {
+	log := &bytes.Buffer{}
+	proxyAddr := startProxyServer(t, log)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "xxx", "error_code": 5}`), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", proxyAddr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, `{"code":5,"message":"error msg: xxx","details":[]}`+"\n", buf.String())
+
+	entries := parseProxyLog(t, log)
+	last := entries[len(entries)-1]
+	assert.Equal(t, "end", last.Event)
+	assert.Equal(t, codes.NotFound, last.Status.Code)
+	assert.Equal(t, "error msg: xxx", last.Status.Message)
+}
+
+// startEndFirstServer starts a server whose methods echo the first request
+// and end without waiting for the client to half-close.
+func startEndFirstServer(t *testing.T) string {
+	s := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			f := &rawFrame{}
+			if err := stream.RecvMsg(f); err != nil {
+				return err
+			}
+			return stream.SendMsg(f)
+		}),
+	)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func TestProxyBidiServerEndsFirst(t *testing.T) {
+	ctx := context.Background()
+	upstream, err := NewGRPCConnection(ctx, startEndFirstServer(t), &GlobalOptions{Insecure: true})
+	require.NoError(t, err)
+	defer upstream.Close()
+	src, err := NewDescriptorSourceFromProtoFiles(nil, "internal/testdata/echo_service.proto")
+	require.NoError(t, err)
+	s := NewProxyServer(upstream, src, &bytes.Buffer{})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := NewGRPCConnection(ctx, l.Addr().String(), &GlobalOptions{Insecure: true})
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true},
+		"/grpcurl.test.Echo/BidiStreamingBulkEcho", grpc.ForceCodec(rawCodec{}))
+	require.NoError(t, err)
+
+	// the client keeps its send side open until the server is done
+	require.NoError(t, stream.SendMsg(&rawFrame{data: []byte("\x0a\x01a")}))
+	f := &rawFrame{}
+	require.NoError(t, stream.RecvMsg(f))
+	assert.Equal(t, []byte("\x0a\x01a"), f.data)
+	assert.Equal(t, io.EOF, stream.RecvMsg(f))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Exchange is a single recorded RPC. Messages are kept as the JSON printed by
+// call so that records can be read and edited by hand.
+type Exchange struct {
+	Method    string            `json:"method"`
+	Metadata  metadata.MD       `json:"metadata,omitempty"`
+	Request   json.RawMessage   `json:"request"`
+	Responses []json.RawMessage `json:"responses,omitempty"`
+	Headers   metadata.MD       `json:"headers,omitempty"`
+	Trailers  metadata.MD       `json:"trailers,omitempty"`
+	Status    *ExchangeStatus   `json:"status"`
+}
+
+// ExchangeStatus is the status an exchange ended with.
+type ExchangeStatus struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+func NewExchangeStatus(st *status.Status) *ExchangeStatus {
+	return &ExchangeStatus{
+		Code:    st.Code(),
+		Message: st.Message(),
+	}
+}
+
+// ExchangeRecorder writes exchanges as JSON Lines. It is safe for concurrent
+// use.
+type ExchangeRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewExchangeRecorder(w io.Writer) *ExchangeRecorder {
+	return &ExchangeRecorder{enc: json.NewEncoder(w)}
+}
+
+// redactedValue replaces the values of credential metadata in records.
+const redactedValue = "REDACTED"
+
+// credentialMetadata are the metadata keys whose values are redacted in
+// records.
+var credentialMetadata = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"x-auth-token":        true,
+}
+
+// redactMetadata returns a copy of md with the values of credential keys
+// replaced by redactedValue.
+func redactMetadata(md metadata.MD) metadata.MD {
+	md = md.Copy()
+	for k, vs := range md {
+		if !credentialMetadata[k] {
+			continue
+		}
+		redacted := make([]string, len(vs))
+		for i := range redacted {
+			redacted[i] = redactedValue
+		}
+		md[k] = redacted
+	}
+	return md
+}
+
+// Record writes e with credentials in its metadata redacted.
+func (r *ExchangeRecorder) Record(e *Exchange) error {
+	redacted := *e
+	redacted.Metadata = redactMetadata(e.Metadata)
+	redacted.Headers = redactMetadata(e.Headers)
+	redacted.Trailers = redactMetadata(e.Trailers)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(&redacted); err != nil {
+		return fmt.Errorf("failed to record exchange: %v", err)
+	}
+	return nil
+}
+
+// ReadExchanges reads exchanges written by ExchangeRecorder.
+func ReadExchanges(r io.Reader) ([]*Exchange, error) {
+	var exchanges []*Exchange
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e Exchange
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exchange %d: %v", len(exchanges)+1, err)
+		}
+		if e.Status == nil {
+			e.Status = &ExchangeStatus{}
+		}
+		exchanges = append(exchanges, &e)
+	}
+	return exchanges, nil
+}
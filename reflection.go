@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	reflectionVersionAuto    = "auto"
+	reflectionVersionV1      = "v1"
+	reflectionVersionV1Alpha = "v1alpha"
+)
+
+var reflectionServices = map[string]string{
+	reflectionVersionV1:      "grpc.reflection.v1.ServerReflection",
+	reflectionVersionV1Alpha: "grpc.reflection.v1alpha.ServerReflection",
+}
+
+// reflectionV1StreamDesc describes grpc.reflection.v1's ServerReflectionInfo.
+// Its messages are wire-compatible with those of v1alpha, so v1 streams send
+// and receive the v1alpha types.
+var reflectionV1StreamDesc = &grpc.StreamDesc{
+	StreamName:    "ServerReflectionInfo",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// reflectionStub is a ServerReflectionClient speaking grpc.reflection.v1 or
+// v1alpha. With version auto, the first stream probes v1 and falls back to
+// v1alpha if the server doesn't implement it.
+type reflectionStub struct {
+	conn     *grpc.ClientConn
+	opts     *GlobalOptions
+	mu       sync.Mutex
+	version  string
+	reported bool
+}
+
+func newReflectionStub(conn *grpc.ClientConn, opts *GlobalOptions) *reflectionStub {
+	version := opts.ReflectionVersion
+	if version == "" {
+		version = reflectionVersionAuto
+	}
+	return &reflectionStub{conn: conn, opts: opts, version: version}
+}
+
+func (s *reflectionStub) ServerReflectionInfo(ctx context.Context, callOpts ...grpc.CallOption) (rpb.ServerReflection_ServerReflectionInfoClient, error) {
+	version, err := s.resolveVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version == reflectionVersionV1Alpha {
+		return rpb.NewServerReflectionClient(s.conn).ServerReflectionInfo(ctx, callOpts...)
+	}
+	return newV1ReflectionStream(ctx, s.conn, callOpts...)
+}
+
+// resolveVersion returns the version of reflection to use, probing the
+// server the first time if needed, and reports it in verbose output.
+func (s *reflectionStub) resolveVersion(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.version {
+	case reflectionVersionAuto:
+		v, err := s.probe(ctx)
+		if err != nil {
+			return "", err
+		}
+		s.version = v
+	case reflectionVersionV1, reflectionVersionV1Alpha:
+	default:
+		return "", fmt.Errorf("unknown reflection version: %s", s.version)
+	}
+
+	if s.opts.Verbose && !s.reported {
+		fmt.Fprintf(s.opts.Output, "using server reflection %s\n", reflectionServices[s.version])
+		s.reported = true
+	}
+	return s.version, nil
+}
+
+// probe lists services with grpc.reflection.v1 and returns v1alpha if the
+// server answers UNIMPLEMENTED.
+func (s *reflectionStub) probe(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := newV1ReflectionStream(ctx, s.conn)
+	if err != nil {
+		return "", err
+	}
+	req := &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	}
+	if err := stream.Send(req); err != nil && err != io.EOF {
+		return "", err
+	}
+	// Send returns io.EOF when the stream failed; Recv returns its status
+	_, err = stream.Recv()
+	stream.CloseSend()
+
+	switch status.Code(err) {
+	case codes.OK:
+		return reflectionVersionV1, nil
+	case codes.Unimplemented:
+		return reflectionVersionV1Alpha, nil
+	default:
+		return "", err
+	}
+}
+
+type v1ReflectionStream struct {
+	grpc.ClientStream
+}
+
+func newV1ReflectionStream(ctx context.Context, conn *grpc.ClientConn, callOpts ...grpc.CallOption) (*v1ReflectionStream, error) {
+	stream, err := conn.NewStream(ctx, reflectionV1StreamDesc, "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", callOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &v1ReflectionStream{stream}, nil
+}
+
+func (x *v1ReflectionStream) Send(m *rpb.ServerReflectionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *v1ReflectionStream) Recv() (*rpb.ServerReflectionResponse, error) {
+	m := new(rpb.ServerReflectionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	test "github.com/kazegusuri/grpcurl/internal"
+	pb "github.com/kazegusuri/grpcurl/internal/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// startReflectionV1Server starts a server exposing only
+// grpc.reflection.v1.ServerReflection.
+func startReflectionV1Server(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	pb.RegisterEchoServer(s, test.NewEchoService())
+	sd := rpb.ServerReflection_ServiceDesc
+	sd.ServiceName = "grpc.reflection.v1.ServerReflection"
+	s.RegisterService(&sd, reflection.NewServer(reflection.ServerOptions{Services: s}))
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func testReflection(t *testing.T, args ...string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs(append([]string{"-k"}, args...))
+	return buf, cmd.Command().Execute()
+}
+
+func TestReflectionV1(t *testing.T) {
+	buf, err := testReflection(t, "-v", "list_services", startReflectionV1Server(t))
+	require.NoError(t, err)
+	assert.Equal(t, "using server reflection grpc.reflection.v1.ServerReflection\n"+
+		"grpc.reflection.v1.ServerReflection\n"+
+		"grpcurl.test.Echo\n", buf.String())
+}
+
+func TestReflectionFallbackV1Alpha(t *testing.T) {
+	buf, err := testReflection(t, "-v", "list_services", addr, "grpcurl.test.v2.Echo")
+	require.NoError(t, err)
+	assert.Equal(t, "using server reflection grpc.reflection.v1alpha.ServerReflection\n"+
+		"grpcurl.test.v2.Echo.Echo\n", buf.String())
+}
+
+func TestReflectionForceVersion(t *testing.T) {
+	_, err := testReflection(t, "--reflection-version", "v1", "list_services", addr)
+	assert.Contains(t, err.Error(), "Unimplemented")
+
+	_, err = testReflection(t, "--reflection-version", "v1alpha", "list_services", startReflectionV1Server(t))
+	assert.Contains(t, err.Error(), "Unimplemented")
+
+	_, err = testReflection(t, "--reflection-version", "v2", "list_services", addr)
+	assert.EqualError(t, err, "unknown reflection version: v2")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/metadata"
+)
+
+type ReplayCommand struct {
+	cmd            *cobra.Command
+	opts           *GlobalOptions
+	addr           string
+	invoker        *UnaryInvoker
+	ignoreMetadata []string
+}
+
+func NewReplayCommand(opts *GlobalOptions) *ReplayCommand {
+	c := &ReplayCommand{
+		cmd: &cobra.Command{
+			Use:   "replay FILE ADDR",
+			Short: "Replay recorded calls and report differences",
+			Example: `
+* record and replay
+echo '{"message": "hello"}' | grpcurl call --record session.jsonl localhost:8888 test.Test.Echo
+grpcurl replay session.jsonl staging:8888
+
+* ignore a header differing between runs
+grpcurl replay --ignore-metadata x-request-id session.jsonl staging:8888
+`,
+			Args:         cobra.ExactArgs(2),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringArrayVar(&c.ignoreMetadata, "ignore-metadata", nil, "header or trailer key to ignore")
+	return c
+}
+
+func (c *ReplayCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ReplayCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	exchanges, err := ReadExchanges(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	c.addr = args[1]
+	conn, err := NewGRPCConnection(ctx, c.addr, c.opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	src, err := NewReflectionSource(ctx, conn, c.addr, c.opts)
+	if err != nil {
+		return err
+	}
+	c.invoker = NewUnaryInvoker(src, conn)
+
+	failed := 0
+	for i, e := range exchanges {
+		diffs, err := c.replay(ctx, e)
+		if err != nil {
+			diffs = append(diffs, err.Error())
+		}
+		if len(diffs) == 0 {
+			fmt.Fprintf(c.opts.Output, "#%d %s: OK\n", i+1, e.Method)
+			continue
+		}
+		failed++
+		fmt.Fprintf(c.opts.Output, "#%d %s: DIFF\n", i+1, e.Method)
+		for _, d := range diffs {
+			fmt.Fprintf(c.opts.Output, "  %s\n", d)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d exchanges differ", failed, len(exchanges))
+	}
+	return nil
+}
+
+// replay sends the recorded request and returns a description of each
+// difference from the recorded outcome.
+func (c *ReplayCommand) replay(ctx context.Context, e *Exchange) ([]string, error) {
+	out, err := c.invoker.InvokeStream(ctx, e.Method, c.outgoingMetadata(e.Metadata), e.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	if out.Status.Code() != e.Status.Code {
+		diffs = append(diffs, fmt.Sprintf("status: recorded %s, got %s", e.Status.Code, out.Status.Code()))
+	}
+	diffs = append(diffs, c.diffMetadata("header", e.Headers, out.Headers)...)
+	diffs = append(diffs, c.diffMetadata("trailer", e.Trailers, out.Trailers)...)
+	if len(out.Responses) != len(e.Responses) {
+		diffs = append(diffs, fmt.Sprintf("responses: recorded %d, got %d", len(e.Responses), len(out.Responses)))
+		return diffs, nil
+	}
+	for i := range out.Responses {
+		equal, err := jsonEqual(e.Responses[i], out.Responses[i])
+		if err != nil {
+			return diffs, err
+		}
+		if !equal {
+			diffs = append(diffs,
+				fmt.Sprintf("response: recorded %s", e.Responses[i]),
+				fmt.Sprintf("response: got      %s", out.Responses[i]))
+		}
+	}
+	return diffs, nil
+}
+
+// outgoingMetadata returns the recorded metadata to send, without the
+// redacted credentials, merged into the headers of the profile, which supply
+// the credentials instead.
+func (c *ReplayCommand) outgoingMetadata(recorded metadata.MD) metadata.MD {
+	md := metadata.MD{}
+	for k, vs := range recorded {
+		if !credentialMetadata[k] {
+			md[k] = vs
+		}
+	}
+	for k, vs := range buildOutgoingMetadata(c.opts.Headers) {
+		if _, ok := md[k]; !ok {
+			md[k] = vs
+		}
+	}
+	return md
+}
+
+// diffMetadata describes the differences of the recorded metadata from md,
+// apart from the ignored keys. Credentials are compared as redacted.
+func (c *ReplayCommand) diffMetadata(kind string, recorded, md metadata.MD) []string {
+	recorded, md = recorded.Copy(), redactMetadata(md)
+	// content-type moves to the trailers of trailers-only responses, which
+	// is a transport detail
+	for _, k := range append([]string{"content-type"}, c.ignoreMetadata...) {
+		k = strings.ToLower(k)
+		delete(recorded, k)
+		delete(md, k)
+	}
+	var diffs []string
+	for _, d := range diffMetadata(recorded, md) {
+		a, b := "none", "none"
+		if d.HasA {
+			a = compactJSON(d.A)
+		}
+		if d.HasB {
+			b = compactJSON(d.B)
+		}
+		diffs = append(diffs, fmt.Sprintf("%s %s: recorded %s, got %s", kind, d.Path, a, b))
+	}
+	return diffs
+}
+
+// jsonEqual reports whether a and b encode the same JSON value regardless of
+// object key order.
+func jsonEqual(a, b []byte) (bool, error) {
+	var av, bv interface{}
+	if err := json.Unmarshal(a, &av); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, &bv); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(av, bv), nil
+}
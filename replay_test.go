@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func testRecord(t *testing.T, file, method, msg string) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(msg), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--record", file, "-H", "x-test: 1", addr, method})
+	require.NoError(t, cmd.Command().Execute())
+}
+
+func testReplay(file string) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "replay", file, addr})
+	return buf, cmd.Command().Execute()
+}
+
+func TestRecord(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	testRecord(t, file, "grpcurl.test.Echo.Echo", `{"value": "xxx"}`)
+	testRecord(t, file, "grpcurl.test.Echo.Echo", `{"value": "yyy", "error_code": 5}`)
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	exchanges, err := ReadExchanges(f)
+	require.NoError(t, err)
+	require.Len(t, exchanges, 2)
+
+	assert.Equal(t, "grpcurl.test.Echo.Echo", exchanges[0].Method)
+	assert.Equal(t, []string{"1"}, exchanges[0].Metadata["x-test"])
+	assert.Equal(t, `{"value":"xxx","error_code":0}`, string(exchanges[0].Request))
+	require.Len(t, exchanges[0].Responses, 1)
+	assert.Equal(t, `{"value":"xxx","error_code":0}`, string(exchanges[0].Responses[0]))
+	assert.Equal(t, codes.OK, exchanges[0].Status.Code)
+
+	assert.Empty(t, exchanges[1].Responses)
+	assert.Equal(t, codes.NotFound, exchanges[1].Status.Code)
+	assert.Equal(t, "error msg: yyy", exchanges[1].Status.Message)
+}
+
+func TestRecordRedactsCredentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "xxx"}`), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--record", file,
+		"-H", "authorization: Bearer secret", "-H", "cookie: session=secret", "-H", "x-test: 1",
+		addr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+	exchanges, err := ReadExchanges(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Len(t, exchanges, 1)
+	assert.Equal(t, []string{redactedValue}, exchanges[0].Metadata["authorization"])
+	assert.Equal(t, []string{redactedValue}, exchanges[0].Metadata["cookie"])
+	assert.Equal(t, []string{"1"}, exchanges[0].Metadata["x-test"])
+
+	// the redacted values aren't sent again
+	out, err := testReplay(file)
+	require.NoError(t, err)
+	assert.Equal(t, "#1 grpcurl.test.Echo.Echo: OK\n", out.String())
+}
+
+func TestReplay(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	testRecord(t, file, "grpcurl.test.Echo.Echo", `{"value": "xxx"}`)
+	testRecord(t, file, "grpcurl.test.Echo.Echo", `{"value": "yyy", "error_code": 5}`)
+
+	buf, err := testReplay(file)
+	require.NoError(t, err)
+	assert.Equal(t, "#1 grpcurl.test.Echo.Echo: OK\n#2 grpcurl.test.Echo.Echo: OK\n", buf.String())
+}
+
+func TestReplayDiff(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	testRecord(t, file, "grpcurl.test.Echo.Echo", `{"value": "xxx"}`)
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	b = bytes.Replace(b, []byte(`"responses":[{"value":"xxx"`), []byte(`"responses":[{"value":"zzz"`), 1)
+	require.NoError(t, ioutil.WriteFile(file, b, 0644))
+
+	buf, err := testReplay(file)
+	assert.EqualError(t, err, "1 of 1 exchanges differ")
+	expected := `#1 grpcurl.test.Echo.Echo: DIFF
+  response: recorded {"value":"zzz","error_code":0}
+  response: got      {"value":"xxx","error_code":0}
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestReplayErrorResponsesDiff(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	testRecord(t, file, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "yyy", "error_code": 5}`)
+
+	// responses are compared even if the call ends with an error
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	b = bytes.Replace(b, []byte(`"request":`), []byte(`"responses":[{"value":"yyy"}],"request":`), 1)
+	require.NoError(t, ioutil.WriteFile(file, b, 0644))
+
+	buf, err := testReplay(file)
+	assert.EqualError(t, err, "1 of 1 exchanges differ")
+	assert.Equal(t, "#1 grpcurl.test.Echo.ServerStreamingEcho: DIFF\n  responses: recorded 1, got 0\n", buf.String())
+}
+
+func TestReplayServerStreaming(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	testRecord(t, file, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "xxx"}`)
+	testRecord(t, file, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "yyy", "error_code": 5}`)
+
+	buf, err := testReplay(file)
+	require.NoError(t, err)
+	assert.Equal(t, "#1 grpcurl.test.Echo.ServerStreamingEcho: OK\n#2 grpcurl.test.Echo.ServerStreamingEcho: OK\n", buf.String())
+}
+
+func TestReplayMetadataDiff(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.jsonl")
+	testRecord(t, file, "grpcurl.test.Echo.Echo", `{"value": "xxx"}`)
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	b = bytes.Replace(b, []byte(`"headers":{`), []byte(`"headers":{"x-server":["a"],"x-request-id":["1"],`), 1)
+	require.NoError(t, ioutil.WriteFile(file, b, 0644))
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "replay", "--ignore-metadata", "X-Request-Id", file, addr})
+	assert.EqualError(t, cmd.Command().Execute(), "1 of 1 exchanges differ")
+	assert.Equal(t, "#1 grpcurl.test.Echo.Echo: DIFF\n  header x-server: recorded \"a\", got none\n", buf.String())
+}
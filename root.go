@@ -2,15 +2,28 @@ package main
 
 import (
 	"io"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 type GlobalOptions struct {
-	Verbose  bool
-	Insecure bool
-	Input    io.Reader
-	Output   io.Writer
+	Verbose           bool
+	Insecure          bool
+	CACert            string
+	Cert              string
+	Key               string
+	ServerName        string
+	Profile           string
+	ConfigFile        string
+	Headers           []string
+	ReflectionVersion string
+	CacheTTL          time.Duration
+	CacheDir          string
+	RefreshCache      bool
+	Color             string
+	Input             io.Reader
+	Output            io.Writer
 }
 
 type RootCommand struct {
@@ -32,10 +45,38 @@ func NewRootCommand(r io.Reader, w io.Writer) *RootCommand {
 			Output: w,
 		},
 	}
+	c.cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return c.opts.applyProfile(cmd, args)
+	}
 	c.cmd.PersistentFlags().BoolVarP(&c.opts.Verbose, "verbose", "v", false, "verbose output")
 	c.cmd.PersistentFlags().BoolVarP(&c.opts.Insecure, "insecure", "k", false, "with insecure")
+	c.cmd.PersistentFlags().StringVar(&c.opts.CACert, "cacert", "", "CA certificate file to verify the server with")
+	c.cmd.PersistentFlags().StringVar(&c.opts.Cert, "cert", "", "client certificate file")
+	c.cmd.PersistentFlags().StringVar(&c.opts.Key, "key", "", "private key file of the client certificate")
+	c.cmd.PersistentFlags().StringVar(&c.opts.ServerName, "servername", "", "server name to verify the certificate of the server against")
+	c.cmd.PersistentFlags().StringVar(&c.opts.Profile, "profile", "", "profile of the config file to take defaults from")
+	c.cmd.PersistentFlags().StringVar(&c.opts.ConfigFile, "config", "", "config file (default ~/.config/grpcurl/config.yaml)")
+	c.cmd.PersistentFlags().StringVar(&c.opts.ReflectionVersion, "reflection-version", reflectionVersionAuto, "version of server reflection: auto, v1 or v1alpha")
+	c.cmd.PersistentFlags().DurationVar(&c.opts.CacheTTL, "cache-ttl", 0, "cache descriptors fetched by reflection for the duration (0 disables the cache)")
+	c.cmd.PersistentFlags().StringVar(&c.opts.CacheDir, "cache-dir", "", "directory of the descriptor cache (default the user cache directory)")
+	c.cmd.PersistentFlags().BoolVar(&c.opts.RefreshCache, "refresh", false, "fetch descriptors by reflection even if they are cached")
+	c.cmd.PersistentFlags().StringVar(&c.opts.Color, "color", colorAuto, "color and indent output: auto (on terminals, unless NO_COLOR is set and not empty), always or never")
 	c.cmd.AddCommand(NewListServicesCommand(c.opts).Command())
+	c.cmd.AddCommand(NewDescribeCommand(c.opts).Command())
 	c.cmd.AddCommand(NewCallCommand(c.opts).Command())
+	c.cmd.AddCommand(NewMockCommand(c.opts).Command())
+	c.cmd.AddCommand(NewReplayCommand(c.opts).Command())
+	c.cmd.AddCommand(NewBatchCommand(c.opts).Command())
+	c.cmd.AddCommand(NewScenarioCommand(c.opts).Command())
+	c.cmd.AddCommand(NewDiffCommand(c.opts).Command())
+	c.cmd.AddCommand(NewCompatCommand(c.opts).Command())
+	c.cmd.AddCommand(NewProxyCommand(c.opts).Command())
+	c.cmd.AddCommand(NewOpenAPICommand(c.opts).Command())
+	c.cmd.AddCommand(NewSchemaCommand(c.opts).Command())
+	c.cmd.AddCommand(NewDocsCommand(c.opts).Command())
+	c.cmd.AddCommand(NewExportCommand(c.opts).Command())
+	c.cmd.AddCommand(NewEncodeCommand(c.opts).Command())
+	c.cmd.AddCommand(NewDecodeCommand(c.opts).Command())
 	return c
 }
 
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a sequence of calls with expectations, read from YAML.
+type Scenario struct {
+	Name    string                 `yaml:"name"`
+	Address string                 `yaml:"address"`
+	Vars    map[string]interface{} `yaml:"vars"`
+	Steps   []*ScenarioStep        `yaml:"steps"`
+}
+
+// ScenarioStep is a single call. Strings in Request and Headers may refer to
+// variables as ${name}; Capture stores values of the response, selected by
+// JSON paths, into variables for later steps.
+type ScenarioStep struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	Request interface{}       `yaml:"request"`
+	Expect  ScenarioExpect    `yaml:"expect"`
+	Capture map[string]string `yaml:"capture"`
+}
+
+// ScenarioExpect holds the assertions of a step. Code defaults to OK, Fields
+// maps JSON paths of the response to expected values and Headers lists the
+// response headers which must be present.
+type ScenarioExpect struct {
+	Code    string                 `yaml:"code"`
+	Fields  map[string]interface{} `yaml:"fields"`
+	Headers []string               `yaml:"headers"`
+	Latency string                 `yaml:"latency"`
+}
+
+func LoadScenario(r io.Reader) (*Scenario, error) {
+	var s Scenario
+	if err := yaml.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario: %v", err)
+	}
+	for i, step := range s.Steps {
+		if step.Method == "" {
+			return nil, fmt.Errorf("step %d: method is required", i+1)
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%d %s", i+1, step.Method)
+		}
+	}
+	return &s, nil
+}
+
+// parseCode parses a status code given by name, such as NOT_FOUND, or by
+// number.
+func parseCode(s string) (codes.Code, error) {
+	var c codes.Code
+	if s == "" {
+		return codes.OK, nil
+	}
+	if _, err := strconv.Atoi(s); err != nil {
+		s = strconv.Quote(strings.ToUpper(s))
+	}
+	if err := c.UnmarshalJSON([]byte(s)); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+// expandString replaces ${name} references in s with values of vars.
+func expandString(s string, vars map[string]interface{}) (string, error) {
+	var err error
+	out := varPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varPattern.FindStringSubmatch(ref)[1]
+		v, ok := vars[name]
+		if !ok {
+			err = fmt.Errorf("undefined variable: %s", name)
+			return ref
+		}
+		if str, ok := v.(string); ok {
+			return str
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	})
+	return out, err
+}
+
+// expandVars expands variable references in every string of v. A string that
+// is a single reference is replaced by the variable's value, keeping its type.
+func expandVars(v interface{}, vars map[string]interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		if m := varPattern.FindStringSubmatch(t); m != nil && m[0] == t {
+			val, ok := vars[m[1]]
+			if !ok {
+				return nil, fmt.Errorf("undefined variable: %s", m[1])
+			}
+			return val, nil
+		}
+		return expandString(t, vars)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			ev, err := expandVars(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = ev
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			ev, err := expandVars(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = ev
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// normalizeJSON converts v to what encoding/json would decode it as, so that
+// values from YAML compare equal to values from responses.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+type ScenarioCommand struct {
+	cmd     *cobra.Command
+	opts    *GlobalOptions
+	junit   string
+	invoker *UnaryInvoker
+}
+
+func NewScenarioCommand(opts *GlobalOptions) *ScenarioCommand {
+	c := &ScenarioCommand{
+		cmd: &cobra.Command{
+			Use:   "test SCENARIO.yaml [ADDR]",
+			Short: "Run a scenario of calls with assertions",
+			Example: `
+* test
+grpcurl test --junit report.xml scenario.yaml localhost:8888
+`,
+			Args:         cobra.RangeArgs(1, 2),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().StringVar(&c.junit, "junit", "", "write JUnit XML report to FILE")
+	return c
+}
+
+func (c *ScenarioCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *ScenarioCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	scenario, err := LoadScenario(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if scenario.Name == "" {
+		scenario.Name = args[0]
+	}
+
+	addr := scenario.Address
+	if len(args) == 2 {
+		addr = args[1]
+	}
+	if addr == "" {
+		return fmt.Errorf("address is required")
+	}
+	conn, err := NewGRPCConnection(ctx, addr, c.opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	src, err := NewReflectionSource(ctx, conn, addr, c.opts)
+	if err != nil {
+		return err
+	}
+	c.invoker = NewUnaryInvoker(src, conn)
+
+	suite := c.run(ctx, scenario)
+	if c.junit != "" {
+		b, err := xml.MarshalIndent(&junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append([]byte(xml.Header), append(b, '\n')...)
+		if err := ioutil.WriteFile(c.junit, b, 0644); err != nil {
+			return fmt.Errorf("failed to write JUnit report: %v", err)
+		}
+	}
+	if suite.Failures > 0 {
+		return fmt.Errorf("%d of %d steps failed", suite.Failures, suite.Tests)
+	}
+	return nil
+}
+
+func (c *ScenarioCommand) run(ctx context.Context, scenario *Scenario) junitTestSuite {
+	vars := map[string]interface{}{}
+	for k, v := range scenario.Vars {
+		vars[k] = v
+	}
+
+	suite := junitTestSuite{Name: scenario.Name}
+	var total time.Duration
+	for _, step := range scenario.Steps {
+		start := time.Now()
+		failures := c.runStep(ctx, step, vars)
+		elapsed := time.Since(start)
+		total += elapsed
+
+		tc := junitTestCase{
+			Name:      step.Name,
+			Classname: scenario.Name,
+			Time:      junitSeconds(elapsed),
+		}
+		suite.Tests++
+		if len(failures) > 0 {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: failures[0],
+				Text:    strings.Join(failures, "\n"),
+			}
+			fmt.Fprintf(c.opts.Output, "--- FAIL: %s (%s)\n", step.Name, elapsed.Round(time.Microsecond))
+			for _, f := range failures {
+				fmt.Fprintf(c.opts.Output, "    %s\n", f)
+			}
+		} else {
+			fmt.Fprintf(c.opts.Output, "--- PASS: %s (%s)\n", step.Name, elapsed.Round(time.Microsecond))
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitSeconds(total)
+	return suite
+}
+
+// runStep calls the step's method and returns the failed expectations.
+func (c *ScenarioCommand) runStep(ctx context.Context, step *ScenarioStep, vars map[string]interface{}) []string {
+	md := buildOutgoingMetadata(c.opts.Headers)
+	for k, v := range step.Headers {
+		ev, err := expandString(v, vars)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		md.Set(k, ev)
+	}
+
+	var body []byte
+	if step.Request != nil {
+		req, err := expandVars(step.Request, vars)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		if body, err = json.Marshal(req); err != nil {
+			return []string{fmt.Sprintf("invalid request: %v", err)}
+		}
+	}
+
+	start := time.Now()
+	out, err := c.invoker.Invoke(ctx, step.Method, md, body)
+	latency := time.Since(start)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var failures []string
+	expectedCode, err := parseCode(step.Expect.Code)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid expected code: %v", err)}
+	}
+	if out.Status.Code() != expectedCode {
+		failures = append(failures, fmt.Sprintf("code: expected %s, got %s: %s", expectedCode, out.Status.Code(), out.Status.Message()))
+	}
+
+	if step.Expect.Latency != "" {
+		bound, err := time.ParseDuration(step.Expect.Latency)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid latency: %v", err))
+		} else if latency > bound {
+			failures = append(failures, fmt.Sprintf("latency: expected at most %s, got %s", bound, latency))
+		}
+	}
+
+	for _, h := range step.Expect.Headers {
+		if len(out.Headers.Get(h)) == 0 {
+			failures = append(failures, fmt.Sprintf("header %s: missing", h))
+		}
+	}
+
+	var resp interface{}
+	if out.Response != nil {
+		if err := json.Unmarshal(out.Response, &resp); err != nil {
+			return append(failures, fmt.Sprintf("invalid response: %v", err))
+		}
+	}
+	paths := make([]string, 0, len(step.Expect.Fields))
+	for path := range step.Expect.Fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		expected, err := expandVars(step.Expect.Fields[path], vars)
+		if err == nil {
+			expected, err = normalizeJSON(expected)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		actual, err := lookupJSONPath(resp, path)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			e, _ := json.Marshal(expected)
+			a, _ := json.Marshal(actual)
+			failures = append(failures, fmt.Sprintf("%s: expected %s, got %s", path, e, a))
+		}
+	}
+
+	for name, path := range step.Capture {
+		v, err := lookupJSONPath(resp, path)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("capture %s: %v", name, err))
+			continue
+		}
+		vars[name] = v
+	}
+	return failures
+}
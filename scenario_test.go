@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testScenario = `
+name: echo
+vars:
+  greeting: hello
+steps:
+  - name: echo
+    method: grpcurl.test.Echo.Echo
+    headers:
+      x-greeting: ${greeting}
+    request:
+      value: ${greeting} world
+    expect:
+      fields:
+        $.value: hello world
+      headers: [content-type]
+      latency: 10s
+    capture:
+      echoed: $.value
+  - name: echo captured
+    method: grpcurl.test.Echo.Echo
+    request:
+      value: ${echoed}
+    expect:
+      fields:
+        value: hello world
+        error_code: 0
+  - name: not found
+    method: grpcurl.test.Echo.Echo
+    request:
+      value: x
+      error_code: 5
+    expect:
+      code: NOT_FOUND
+  - name: failing
+    method: grpcurl.test.Everything.Simple
+    request:
+      string_value: a
+    expect:
+      fields:
+        $.string_value: b
+        $.missing: 1
+`
+
+func TestScenario(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "scenario.yaml")
+	report := filepath.Join(dir, "report.xml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(testScenario), 0644))
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "test", "--junit", report, file, addr})
+	err := cmd.Command().Execute()
+	assert.EqualError(t, err, "1 of 4 steps failed")
+
+	out := buf.String()
+	assert.Contains(t, out, "--- PASS: echo (")
+	assert.Contains(t, out, "--- PASS: echo captured (")
+	assert.Contains(t, out, "--- PASS: not found (")
+	assert.Contains(t, out, "--- FAIL: failing (")
+	assert.Contains(t, out, `    $.missing: no such field "missing"`)
+	assert.Contains(t, out, `    $.string_value: expected "b", got "a"`)
+
+	b, err := ioutil.ReadFile(report)
+	require.NoError(t, err)
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(b, &suites))
+	require.Len(t, suites.Suites, 1)
+	assert.Equal(t, "echo", suites.Suites[0].Name)
+	assert.Equal(t, 4, suites.Suites[0].Tests)
+	assert.Equal(t, 1, suites.Suites[0].Failures)
+	require.Len(t, suites.Suites[0].Cases, 4)
+	assert.Nil(t, suites.Suites[0].Cases[0].Failure)
+	require.NotNil(t, suites.Suites[0].Cases[3].Failure)
+}
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]interface{}{"s": "x", "n": 1}
+	v, err := expandVars(map[string]interface{}{
+		"a": "${s}-${n}",
+		"b": "${n}",
+		"c": []interface{}{"${s}"},
+	}, vars)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": "x-1", "b": 1, "c": []interface{}{"x"}}, v)
+
+	_, err = expandVars("${undefined}", vars)
+	assert.EqualError(t, err, "undefined variable: undefined")
+}
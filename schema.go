@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+type SchemaCommand struct {
+	cmd         *cobra.Command
+	opts        *GlobalOptions
+	camelCase   bool
+	importPaths []string
+}
+
+func NewSchemaCommand(opts *GlobalOptions) *SchemaCommand {
+	c := &SchemaCommand{
+		cmd: &cobra.Command{
+			Use:   "schema ADDR MESSAGE",
+			Short: "Generate a JSON Schema of a message",
+			Long: `Generate a JSON Schema of the JSON representation of MESSAGE.
+
+The schema follows the proto3 JSON mapping, with the field names of the proto
+source unless --camel-case is given, as printed by call. ADDR is the address
+of a server supporting reflection, a protoset file or a directory of proto
+files.`,
+			Example: `
+* schema
+grpcurl schema localhost:8888 test.EchoMessage > echo.schema.json
+`,
+			Args:         cobra.ExactArgs(2),
+			SilenceUsage: true,
+		},
+		opts: opts,
+	}
+	c.cmd.RunE = c.Run
+	c.cmd.Flags().BoolVar(&c.camelCase, "camel-case", false, "use lowerCamelCase JSON names instead of proto field names")
+	c.cmd.Flags().StringArrayVarP(&c.importPaths, "import-path", "I", nil, "import path to resolve proto imports")
+	return c
+}
+
+func (c *SchemaCommand) Command() *cobra.Command {
+	return c.cmd
+}
+
+func (c *SchemaCommand) Run(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	src, closeSrc, err := OpenDescriptorSource(ctx, args[0], c.opts, c.importPaths)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+
+	md, err := src.ResolveMessage(args[1])
+	if err != nil {
+		return fmt.Errorf("message couldn't be resolved: %v", err)
+	}
+
+	b := newJSONSchemaBuilder("#/$defs/", !c.camelCase, false)
+	schema := b.messageSchema(md)
+	schema["$schema"] = jsonSchemaDialect
+	if len(b.defs) > 0 {
+		schema["$defs"] = b.defs
+	}
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.opts.Output, "%s\n", out)
+	return nil
+}
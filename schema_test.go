@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSchema(t *testing.T, args ...string) map[string]interface{} {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "schema"}, args...))
+	require.NoError(t, cmd.Command().Execute())
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &schema))
+	return schema
+}
+
+func TestSchemaOneof(t *testing.T) {
+	schema := testSchema(t, addr, "grpcurl.test.OneofMessage")
+	assert.Equal(t, jsonSchemaDialect, schema["$schema"])
+	assert.Equal(t, "#/$defs/grpcurl.test.OneofMessage", schema["$ref"])
+
+	def := lookup(t, schema, "$defs", "grpcurl.test.OneofMessage")
+	assert.Equal(t, map[string]interface{}{"type": "string"}, lookup(t, def, "properties", "string_value"))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"required": []interface{}{"int32_value"}},
+		map[string]interface{}{"required": []interface{}{"string_value"}},
+		map[string]interface{}{"not": map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"required": []interface{}{"int32_value"}},
+			map[string]interface{}{"required": []interface{}{"string_value"}},
+		}}},
+	}, lookup(t, def, "oneOf"))
+	assert.Equal(t, "#/$defs/grpcurl.test.Oneof",
+		lookup(t, def, "properties", "repeated_oneof_values", "items", "$ref"))
+}
+
+func TestSchemaMapCamelCase(t *testing.T) {
+	schema := testSchema(t, "--camel-case", addr, "grpcurl.test.MapMessage")
+	def := lookup(t, schema, "$defs", "grpcurl.test.MapMessage")
+	assert.Equal(t, map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": map[string]interface{}{"$ref": "#/$defs/grpcurl.test.NumericEnum"},
+	}, lookup(t, def, "properties", "mappedEnumValue"))
+	assert.Equal(t, map[string]interface{}{"type": "string", "enum": []interface{}{"ZERO", "ONE", "TWO"}},
+		lookup(t, schema, "$defs", "grpcurl.test.NumericEnum"))
+}
+
+func TestSchemaWellKnownTypes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "wkt.proto"), []byte(`syntax = "proto3";
+package wkt;
+import "google/protobuf/duration.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+// Event happened.
+message Event {
+  // when it happened
+  google.protobuf.Timestamp at = 1;
+  google.protobuf.Duration took = 2;
+  google.protobuf.Int64Value count = 3;
+  google.protobuf.Struct attrs = 4;
+  google.protobuf.NullValue nothing = 5;
+}
+`), 0644))
+
+	schema := testSchema(t, dir, "wkt.Event")
+	def := lookup(t, schema, "$defs", "wkt.Event")
+	assert.Equal(t, "Event happened.", def.(map[string]interface{})["description"])
+	assert.Equal(t, map[string]interface{}{
+		"type":        "string",
+		"format":      "date-time",
+		"description": "when it happened",
+	}, lookup(t, def, "properties", "at"))
+	assert.Equal(t, "string", lookup(t, def, "properties", "took", "type"))
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "int64"}, lookup(t, def, "properties", "count"))
+	assert.Equal(t, map[string]interface{}{"type": "object"}, lookup(t, def, "properties", "attrs"))
+	assert.Equal(t, map[string]interface{}{"type": "null"}, lookup(t, def, "properties", "nothing"))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateFuncs returns the helper functions of request templates, with the
+// strings they take from outside passed through escape.
+func templateFuncs(escape func(string) string) template.FuncMap {
+	return template.FuncMap{
+		"uuid": newUUID,
+		"now": func() string {
+			return time.Now().UTC().Format(time.RFC3339)
+		},
+		"base64": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"env": func(name string) string {
+			return escape(os.Getenv(name))
+		},
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// RequestTemplate expands request bodies and header values as Go templates,
+// then expands ${name} references in the result. Both see the environment,
+// overridden by the variables of var files, overridden by --var variables.
+type RequestTemplate struct {
+	vars map[string]interface{}
+	// jsonVars are vars with their strings escaped for JSON strings
+	jsonVars map[string]interface{}
+}
+
+// NewRequestTemplate creates a RequestTemplate from the YAML (or JSON) maps
+// of varFiles and the name=value pairs of vars.
+func NewRequestTemplate(varFiles, vars []string) (*RequestTemplate, error) {
+	t := &RequestTemplate{vars: map[string]interface{}{}}
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		t.vars[parts[0]] = parts[1]
+	}
+	for _, filename := range varFiles {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read var file: %v", err)
+		}
+		var fileVars map[string]interface{}
+		if err := yaml.Unmarshal(b, &fileVars); err != nil {
+			return nil, fmt.Errorf("failed to parse var file %s: %v", filename, err)
+		}
+		for k, v := range fileVars {
+			t.vars[k] = v
+		}
+	}
+	for _, kv := range vars {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid var: %s (must be name=value)", kv)
+		}
+		t.vars[parts[0]] = parts[1]
+	}
+	t.jsonVars = escapeJSONStrings(t.vars).(map[string]interface{})
+	return t, nil
+}
+
+// Expand expands the templates and variable references in s.
+func (t *RequestTemplate) Expand(s string) (string, error) {
+	return t.expand(s, t.vars, func(s string) string { return s })
+}
+
+// ExpandJSON expands the templates and variable references in the JSON s,
+// escaping the strings of variables so that they can be placed in JSON
+// strings.
+func (t *RequestTemplate) ExpandJSON(s string) (string, error) {
+	return t.expand(s, t.jsonVars, escapeJSONString)
+}
+
+func (t *RequestTemplate) expand(s string, vars map[string]interface{}, escape func(string) string) (string, error) {
+	tmpl, err := template.New("request").Funcs(templateFuncs(escape)).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, vars); err != nil {
+		return "", fmt.Errorf("failed to expand template: %v", err)
+	}
+	return expandString(buf.String(), vars)
+}
+
+// escapeJSONString escapes s to be placed between the quotes of a JSON
+// string.
+func escapeJSONString(s string) string {
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	e.Encode(s)
+	b := bytes.TrimSpace(buf.Bytes())
+	return string(b[1 : len(b)-1])
+}
+
+// escapeJSONStrings returns v with every string in it escaped by
+// escapeJSONString.
+func escapeJSONStrings(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return escapeJSONString(v)
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			res[k] = escapeJSONStrings(e)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			res[i] = escapeJSONStrings(e)
+		}
+		return res
+	}
+	return v
+}
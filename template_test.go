@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func testTemplateCall(t *testing.T, body string, args ...string) (map[string]interface{}, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(body), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "call", addr, "grpcurl.test.Echo.Echo"}, args...))
+	if err := cmd.Command().Execute(); err != nil {
+		return nil, err
+	}
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &resp))
+	return resp, nil
+}
+
+func TestTemplateVars(t *testing.T) {
+	os.Setenv("GRPCURL_TEST_ENV", "env")
+	defer os.Unsetenv("GRPCURL_TEST_ENV")
+	varFile := filepath.Join(t.TempDir(), "vars.yaml")
+	require.NoError(t, ioutil.WriteFile(varFile, []byte("name: file\ngreeting: file\ncode: 0\n"), 0644))
+
+	resp, err := testTemplateCall(t,
+		"{\"value\": \"{{.greeting}} ${name} ${GRPCURL_TEST_ENV} {{base64 `hi`}}\", \"error_code\": ${code}}",
+		"--var-file", varFile, "--var", "greeting=hello")
+	require.NoError(t, err)
+	assert.Equal(t, "hello file env aGk=", resp["value"])
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	resp, err := testTemplateCall(t, `{"value": "{{uuid}} {{now}}"}`, "--template")
+	require.NoError(t, err)
+	parts := strings.Split(resp["value"].(string), " ")
+	require.Len(t, parts, 2)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), parts[0])
+	ts, err := time.Parse(time.RFC3339, parts[1])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), ts, time.Minute)
+}
+
+func TestTemplateHeaders(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "record.jsonl")
+	_, err := testTemplateCall(t, `{}`, "--var", "id=42", "-H", "x-id: ${id}", "--record", record)
+	require.NoError(t, err)
+
+	b, err := ioutil.ReadFile(record)
+	require.NoError(t, err)
+	var e struct {
+		Metadata metadata.MD `json:"metadata"`
+	}
+	require.NoError(t, json.Unmarshal(b, &e))
+	assert.Equal(t, []string{"42"}, e.Metadata.Get("x-id"))
+}
+
+func TestTemplateErrors(t *testing.T) {
+	_, err := testTemplateCall(t, `{"value": "${undefined_var}"}`, "--template")
+	assert.EqualError(t, err, "undefined variable: undefined_var")
+
+	_, err = testTemplateCall(t, `{"value": "{{.undefined_var}}"}`, "--template")
+	assert.Error(t, err)
+
+	_, err = testTemplateCall(t, `{}`, "--var", "novalue")
+	assert.EqualError(t, err, "invalid var: novalue (must be name=value)")
+}
+
+func TestTemplateOptIn(t *testing.T) {
+	os.Setenv("GRPCURL_TEST_ENV", "env")
+	defer os.Unsetenv("GRPCURL_TEST_ENV")
+	resp, err := testTemplateCall(t, `{"value": "${GRPCURL_TEST_ENV} ${undefined_var} {{"}`, "-H", "x-id: ${GRPCURL_TEST_ENV}")
+	require.NoError(t, err)
+	assert.Equal(t, "${GRPCURL_TEST_ENV} ${undefined_var} {{", resp["value"])
+}
+
+func TestTemplateEscapesJSON(t *testing.T) {
+	varFile := filepath.Join(t.TempDir(), "vars.yaml")
+	require.NoError(t, ioutil.WriteFile(varFile, []byte("nested:\n  msg: 'c\\d'\n"), 0644))
+	resp, err := testTemplateCall(t, `{"value": "{{.msg}} ${msg} {{.nested.msg}}"}`,
+		"--var", `msg=a"b`, "--var-file", varFile)
+	require.NoError(t, err)
+	assert.Equal(t, `a"b a"b c\d`, resp["value"])
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// multiStatsHandler passes stats to each of its handlers, as a connection
+// takes a single stats.Handler.
+type multiStatsHandler []stats.Handler
+
+// TagRPC implements stats.Handler.TagRPC
+func (hs multiStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	for _, h := range hs {
+		ctx = h.TagRPC(ctx, info)
+	}
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.HandleRPC
+func (hs multiStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	for _, h := range hs {
+		h.HandleRPC(ctx, s)
+	}
+}
+
+// TagConn implements stats.Handler.TagConn
+func (hs multiStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	for _, h := range hs {
+		ctx = h.TagConn(ctx, info)
+	}
+	return ctx
+}
+
+// HandleConn implements stats.Handler.HandleConn
+func (hs multiStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	for _, h := range hs {
+		h.HandleConn(ctx, s)
+	}
+}
+
+// tracer is a stats.Handler and a dialHook printing every event of the
+// connections and RPCs of a client, including those of server reflection,
+// with the time since the tracer started:
+//
+//	+0.000212s [channel 1] State IDLE
+//	+0.000390s [dial 1] LookupStart host=localhost
+//	+0.000731s [conn 2] ConnBegin remote=127.0.0.1:8888 local=127.0.0.1:50122
+//	+0.001024s [rpc 3] Begin method=/test.Test/Echo
+//	+0.001102s [rpc 3] OutPayload size=7 wire=12
+type tracer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	start  time.Time
+	lastID int
+	closed bool
+	// dials maps the IDs of dialEvents to those printed
+	dials map[int]int
+}
+
+func newTracer(w io.Writer) *tracer {
+	return &tracer{w: w, start: time.Now(), dials: map[int]int{}}
+}
+
+type traceConnKey struct{}
+
+type traceRPCKey struct{}
+
+type traceRPC struct {
+	id     int
+	method string
+}
+
+type traceConn struct {
+	id     int
+	remote string
+	local  string
+}
+
+func (t *tracer) nextID() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastID++
+	return t.lastID
+}
+
+// dialID returns the ID printed for the dial or handshake with the ID of
+// dialEvents, numbered along with connections and RPCs.
+func (t *tracer) dialID(eventID int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.dials[eventID]; !ok {
+		t.lastID++
+		t.dials[eventID] = t.lastID
+	}
+	return t.dials[eventID]
+}
+
+func (t *tracer) printf(kind string, id int, format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	fmt.Fprintf(t.w, "+%.6fs [%s %d] %s\n", time.Since(t.start).Seconds(), kind, id, fmt.Sprintf(format, args...))
+}
+
+// Close stops printing, so that events of connections closing in the
+// background don't outlive the command.
+func (t *tracer) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+// TagRPC implements stats.Handler.TagRPC
+func (t *tracer) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, traceRPCKey{}, &traceRPC{id: t.nextID(), method: info.FullMethodName})
+}
+
+// HandleRPC implements stats.Handler.HandleRPC
+func (t *tracer) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	rpc, ok := ctx.Value(traceRPCKey{}).(*traceRPC)
+	if !ok {
+		rpc = &traceRPC{}
+	}
+	id := rpc.id
+	switch s := s.(type) {
+	case *stats.Begin:
+		t.printf("rpc", id, "Begin method=%s", rpc.method)
+	case *stats.OutHeader:
+		t.printf("rpc", id, "OutHeader%s%s", traceCompression(s.Compression), traceKeys(s.Header))
+	case *stats.OutPayload:
+		t.printf("rpc", id, "OutPayload size=%d wire=%d", s.Length, s.WireLength)
+	case *stats.OutTrailer:
+		t.printf("rpc", id, "OutTrailer%s", traceKeys(s.Trailer))
+	case *stats.InHeader:
+		t.printf("rpc", id, "InHeader wire=%d%s%s", s.WireLength, traceCompression(s.Compression), traceKeys(s.Header))
+	case *stats.InPayload:
+		t.printf("rpc", id, "InPayload size=%d wire=%d", s.Length, s.WireLength)
+	case *stats.InTrailer:
+		t.printf("rpc", id, "InTrailer wire=%d%s", s.WireLength, traceKeys(s.Trailer))
+	case *stats.End:
+		st, _ := status.FromError(s.Error)
+		if st.Message() != "" {
+			t.printf("rpc", id, "End status=%s message=%q", st.Code(), st.Message())
+		} else {
+			t.printf("rpc", id, "End status=%s", st.Code())
+		}
+	default:
+		t.printf("rpc", id, "%T", s)
+	}
+}
+
+// TagConn implements stats.Handler.TagConn
+func (t *tracer) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	c := &traceConn{id: t.nextID()}
+	if info.RemoteAddr != nil {
+		c.remote = info.RemoteAddr.String()
+	}
+	if info.LocalAddr != nil {
+		c.local = info.LocalAddr.String()
+	}
+	return context.WithValue(ctx, traceConnKey{}, c)
+}
+
+// HandleConn implements stats.Handler.HandleConn
+func (t *tracer) HandleConn(ctx context.Context, s stats.ConnStats) {
+	c, ok := ctx.Value(traceConnKey{}).(*traceConn)
+	if !ok {
+		c = &traceConn{}
+	}
+	switch s.(type) {
+	case *stats.ConnBegin:
+		t.printf("conn", c.id, "ConnBegin remote=%s local=%s", c.remote, c.local)
+	case *stats.ConnEnd:
+		t.printf("conn", c.id, "ConnEnd remote=%s", c.remote)
+	default:
+		t.printf("conn", c.id, "%T", s)
+	}
+}
+
+// HandleDial implements dialHook.HandleDial
+func (t *tracer) HandleDial(e *dialEvent) {
+	id := t.dialID(e.ID)
+	key := "addr"
+	switch e.Phase {
+	case dialLookupStart, dialLookupDone:
+		key = "host"
+	case dialHandshakeStart, dialHandshakeDone:
+		key = "remote"
+	}
+	if e.Err != nil {
+		t.printf("dial", id, "%s %s=%s error=%q", e.Phase, key, e.Addr, e.Err.Error())
+		return
+	}
+	t.printf("dial", id, "%s %s=%s", e.Phase, key, e.Addr)
+}
+
+// WatchState prints the connectivity state of conn, and then its changes
+// until ctx is done.
+func (t *tracer) WatchState(ctx context.Context, conn *grpc.ClientConn) {
+	id := t.nextID()
+	state := conn.GetState()
+	t.printf("channel", id, "State %s", state)
+	go func() {
+		for conn.WaitForStateChange(ctx, state) {
+			state = conn.GetState()
+			t.printf("channel", id, "State %s", state)
+		}
+	}()
+}
+
+func traceCompression(compression string) string {
+	if compression == "" {
+		return ""
+	}
+	return " compression=" + compression
+}
+
+// traceKeys lists the keys of md, leaving out their values which may be
+// credentials.
+func traceKeys(md metadata.MD) string {
+	if len(md) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return " keys=" + strings.Join(keys, ",")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	test "github.com/kazegusuri/grpcurl/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	traceLineRegexp = regexp.MustCompile(`^\+(\d+\.\d{6})s \[(conn|rpc|dial|channel) (\d+)\] (.*)$`)
+	// the wire lengths of headers depend on their HPACK compression
+	traceHeaderWireRegexp = regexp.MustCompile(`^(InHeader|InTrailer) wire=\d+`)
+)
+
+// testTrace calls method with --trace, returning the output lines and the
+// events of the RPC of method printed to stderr, without timestamps or header
+// wire lengths.
+func testTrace(t *testing.T, body, method string, args ...string) ([]string, []string) {
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(body), buf)
+	cmd.Command().SetErr(errBuf)
+	cmd.Command().SetArgs(append([]string{"-k", "call", "--trace", addr, method}, args...))
+	require.NoError(t, cmd.Command().Execute())
+	output := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	return output, traceEvents(t, errBuf.String(), method)
+}
+
+// traceEvents parses trace, returning the events of the RPC of method.
+func traceEvents(t *testing.T, trace, method string) []string {
+	var events []string
+	var rpc string
+	var last float64
+	connBegin := false
+	for _, line := range strings.Split(strings.TrimSuffix(trace, "\n"), "\n") {
+		m := traceLineRegexp.FindStringSubmatch(line)
+		require.NotNil(t, m, line)
+		ts, err := strconv.ParseFloat(m[1], 64)
+		require.NoError(t, err)
+		assert.True(t, ts >= last, line)
+		last = ts
+
+		if m[2] == "conn" {
+			connBegin = connBegin || strings.HasPrefix(m[4], "ConnBegin remote="+strings.Replace(addr, "localhost", "127.0.0.1", 1))
+			continue
+		}
+		if m[4] == "Begin method=/"+strings.Replace(method, ".Echo.", ".Echo/", 1) {
+			rpc = m[3]
+		}
+		if m[3] == rpc {
+			events = append(events, traceHeaderWireRegexp.ReplaceAllString(m[4], "$1"))
+		}
+	}
+	assert.True(t, connBegin, trace)
+	return events
+}
+
+// traceConnEvents parses trace, returning the events of the channel, dials
+// and connections prefixed by their kind.
+func traceConnEvents(t *testing.T, trace string) []string {
+	var events []string
+	for _, line := range strings.Split(strings.TrimSuffix(trace, "\n"), "\n") {
+		m := traceLineRegexp.FindStringSubmatch(line)
+		require.NotNil(t, m, line)
+		if m[2] != "rpc" {
+			events = append(events, m[2]+" "+m[4])
+		}
+	}
+	return events
+}
+
+// assertEventsInOrder asserts that events has an event starting with each of
+// prefixes, in that order.
+func assertEventsInOrder(t *testing.T, events []string, prefixes ...string) {
+	i := 0
+	for _, e := range events {
+		if i < len(prefixes) && strings.HasPrefix(e, prefixes[i]) {
+			i++
+		}
+	}
+	if i < len(prefixes) {
+		assert.Fail(t, "missing event "+prefixes[i], strings.Join(events, "\n"))
+	}
+}
+
+func TestTrace(t *testing.T) {
+	output, events := testTrace(t, `{"value": "hi"}`, "grpcurl.test.Echo.Echo")
+	assert.Equal(t, []string{`{"value":"hi","error_code":0}`}, output)
+	require.NotEmpty(t, events)
+	assert.Equal(t, "Begin method=/grpcurl.test.Echo/Echo", events[0])
+	assert.Equal(t, "End status=OK", events[len(events)-1])
+	// the trailer of a unary call may be handled before its message
+	assert.ElementsMatch(t, []string{
+		"Begin method=/grpcurl.test.Echo/Echo",
+		"OutHeader keys=user-agent",
+		"OutPayload size=4 wire=9",
+		"InHeader keys=content-type",
+		"InPayload size=4 wire=9",
+		"InTrailer",
+		"End status=OK",
+	}, events)
+}
+
+func TestTraceStream(t *testing.T) {
+	output, events := testTrace(t, `{"value": "hi", "error_code": 5}`, "grpcurl.test.Echo.ServerStreamingEcho",
+		"-H", "x-id: 1", "-w", "%{status}\n")
+	require.Len(t, output, 2)
+	assert.Equal(t, "NotFound", output[1])
+	assert.Equal(t, []string{
+		"Begin method=/grpcurl.test.Echo/ServerStreamingEcho",
+		"OutHeader keys=user-agent,x-id",
+		"OutPayload size=6 wire=11",
+		// a trailers-only response
+		"InTrailer keys=content-type",
+		`End status=NotFound message="error msg: hi"`,
+	}, events)
+}
+
+func TestTraceConnection(t *testing.T) {
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "hi"}`), buf)
+	cmd.Command().SetErr(errBuf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--trace", addr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	events := traceConnEvents(t, errBuf.String())
+	require.NotEmpty(t, events)
+	assert.True(t, strings.HasPrefix(events[0], "channel State "), events[0])
+	assertEventsInOrder(t, events,
+		"dial LookupStart host=localhost",
+		"dial LookupDone host=localhost",
+		// localhost may be tried on ::1 first
+		"dial ConnectStart addr=",
+		"dial ConnectDone addr=127.0.0.1:"+port,
+		"conn ConnBegin remote=127.0.0.1:"+port,
+	)
+	assert.Contains(t, events, "channel State READY")
+	for _, e := range events {
+		assert.NotContains(t, e, "Handshake")
+	}
+}
+
+func TestTraceTLSHandshake(t *testing.T) {
+	// a certificate for 127.0.0.1
+	hs := httptest.NewUnstartedServer(nil)
+	hs.StartTLS()
+	hs.Close()
+	cacert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(cacert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: hs.Certificate().Raw}), 0644))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := test.NewServer()
+	go s.Serve(tls.NewListener(l, &tls.Config{Certificates: hs.TLS.Certificates, NextProtos: []string{"h2"}}))
+	t.Cleanup(s.Stop)
+	server := l.Addr().String()
+
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "hi"}`), buf)
+	cmd.Command().SetErr(errBuf)
+	cmd.Command().SetArgs([]string{"--cacert", cacert, "call", "--trace", server, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, `{"value":"hi","error_code":0}`+"\n", buf.String())
+
+	assertEventsInOrder(t, traceConnEvents(t, errBuf.String()),
+		"dial LookupStart host=127.0.0.1",
+		"dial ConnectDone addr="+server,
+		"dial HandshakeStart remote="+server,
+		"dial HandshakeDone remote="+server,
+		"conn ConnBegin remote="+server,
+	)
+}
+
+func TestTraceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.log")
+	buf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(`{"value": "hi"}`), buf)
+	cmd.Command().SetErr(errBuf)
+	cmd.Command().SetArgs([]string{"-k", "call", "--trace-file", path, addr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, `{"value":"hi","error_code":0}`+"\n", buf.String())
+	assert.Empty(t, errBuf.String())
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	events := traceEvents(t, string(b), "grpcurl.test.Echo.Echo")
+	assert.Contains(t, events, "End status=OK")
+}
+
+func TestTraceErrors(t *testing.T) {
+	cmd := NewRootCommand(strings.NewReader(`{}`), &bytes.Buffer{})
+	cmd.Command().SetArgs([]string{"-k", "call", "--trace", "--protocol", protocolConnect, "--proto", "echo_service.proto", addr, "grpcurl.test.Echo.Echo"})
+	assert.EqualError(t, cmd.Command().Execute(), "--trace can only be used with --protocol grpc")
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// httpRule returns the google.api.http rule of mdesc, or nil if it has none.
+func httpRule(mdesc *desc.MethodDescriptor) *annotations.HttpRule {
+	opts := mdesc.GetMethodOptions()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+		return nil
+	}
+	v, err := proto.GetExtension(opts, annotations.E_Http)
+	if err != nil {
+		return nil
+	}
+	rule, _ := v.(*annotations.HttpRule)
+	return rule
+}
+
+// httpRulePattern returns the HTTP method and path template of rule.
+func httpRulePattern(rule *annotations.HttpRule) (string, string) {
+	switch {
+	case rule.GetGet() != "":
+		return http.MethodGet, rule.GetGet()
+	case rule.GetPut() != "":
+		return http.MethodPut, rule.GetPut()
+	case rule.GetPost() != "":
+		return http.MethodPost, rule.GetPost()
+	case rule.GetDelete() != "":
+		return http.MethodDelete, rule.GetDelete()
+	case rule.GetPatch() != "":
+		return http.MethodPatch, rule.GetPatch()
+	case rule.GetCustom() != nil:
+		return rule.GetCustom().GetKind(), rule.GetCustom().GetPath()
+	}
+	return "", ""
+}
+
+// formatHTTPRule formats rule and its additional bindings, such as
+// "POST /v1/echo body:*, GET /v1/echo/{value}".
+func formatHTTPRule(rule *annotations.HttpRule) string {
+	var bindings []string
+	for _, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+		method, path := httpRulePattern(r)
+		s := method + " " + path
+		if r.GetBody() != "" {
+			s += " body:" + r.GetBody()
+		}
+		bindings = append(bindings, s)
+	}
+	return strings.Join(bindings, ", ")
+}
+
+// pathVarPattern matches a variable of a path template: {field.path} or
+// {field.path=segments}.
+var pathVarPattern = regexp.MustCompile(`\{([^}=]+)(=([^}]*))?\}`)
+
+// httpTransport is a CallTransport sending calls to an HTTP/JSON gateway
+// as the REST requests described by their google.api.http rules.
+type httpTransport struct {
+	client      *http.Client
+	baseURL     string
+	marshaler   *jsonpb.Marshaler
+	unmarshaler *jsonpb.Unmarshaler
+}
+
+func NewHTTPTransport(client *http.Client, baseURL string) CallTransport {
+	return &httpTransport{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		marshaler: &jsonpb.Marshaler{
+			OrigName:    true,
+			AnyResolver: DynamicAnyResolver{},
+		},
+		unmarshaler: &jsonpb.Unmarshaler{
+			AllowUnknownFields: true,
+			AnyResolver:        DynamicAnyResolver{},
+		},
+	}
+}
+
+// transcodeRequest builds the method, URL and body of the REST request for
+// req following rule.
+func (t *httpTransport) transcodeRequest(rule *annotations.HttpRule, req proto.Message) (string, string, []byte, error) {
+	method, template := httpRulePattern(rule)
+	if method == "" {
+		return "", "", nil, fmt.Errorf("HTTP rule has no pattern")
+	}
+
+	s, err := t.marshaler.MarshalToString(req)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("marshal %v", err)
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var fields map[string]interface{}
+	if err := dec.Decode(&fields); err != nil {
+		return "", "", nil, err
+	}
+
+	var perr error
+	path := pathVarPattern.ReplaceAllStringFunc(template, func(v string) string {
+		m := pathVarPattern.FindStringSubmatch(v)
+		value, ok := takeField(fields, m[1])
+		if !ok {
+			perr = fmt.Errorf("path variable %s is not set", m[1])
+			return ""
+		}
+		s := queryValue(value)
+		if strings.Contains(m[3], "/") || strings.Contains(m[3], "**") {
+			// multi-segment variables such as {name=shelves/*/books/*}
+			// keep their slashes
+			segs := strings.Split(s, "/")
+			for i := range segs {
+				segs[i] = url.PathEscape(segs[i])
+			}
+			return strings.Join(segs, "/")
+		}
+		return url.PathEscape(s)
+	})
+	if perr != nil {
+		return "", "", nil, perr
+	}
+
+	var body []byte
+	switch rule.GetBody() {
+	case "":
+	case "*":
+		body, err = json.Marshal(fields)
+		fields = nil
+	default:
+		if value, ok := takeField(fields, rule.GetBody()); ok {
+			body, err = json.Marshal(value)
+		}
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	q := url.Values{}
+	for k, v := range fields {
+		addQueryParams(q, k, v)
+	}
+	u := t.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return method, u, body, nil
+}
+
+// takeField removes the value at the dotted field path from fields and
+// returns it.
+func takeField(fields map[string]interface{}, path string) (interface{}, bool) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		m, ok := fields[name].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		fields = m
+	}
+	last := names[len(names)-1]
+	v, ok := fields[last]
+	delete(fields, last)
+	return v, ok
+}
+
+func queryValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// addQueryParams adds v as query parameters named after the field path of
+// key, repeating the parameter for repeated fields.
+func addQueryParams(q url.Values, key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			addQueryParams(q, key+"."+k, e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			addQueryParams(q, key, e)
+		}
+	default:
+		q.Add(key, queryValue(v))
+	}
+}
+
+// restStatusCode maps the HTTP status of a REST response without a status
+// body to a code, inverting the mapping used by HTTP/JSON gateways.
+func restStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// restStatus decodes a google.rpc.Status from the JSON body of a failed
+// REST call.
+func (t *httpTransport) restStatus(httpStatus int, data []byte) *status.Status {
+	var sp spb.Status
+	if err := t.unmarshaler.Unmarshal(bytes.NewReader(data), &sp); err != nil || sp.GetCode() == 0 {
+		return status.Newf(restStatusCode(httpStatus), "unexpected HTTP status %d: %s", httpStatus, bytes.TrimSpace(data))
+	}
+	return status.FromProto(&sp)
+}
+
+func (t *httpTransport) Invoke(ctx context.Context, mdesc *desc.MethodDescriptor, req proto.Message, onResponse func(proto.Message) error) (metadata.MD, metadata.MD, error) {
+	if mdesc.IsClientStreaming() {
+		return nil, nil, fmt.Errorf("client streaming method is not supported over HTTP")
+	}
+	rule := httpRule(mdesc)
+	if rule == nil {
+		return nil, nil, fmt.Errorf("method %s has no google.api.http rule", mdesc.GetFullyQualifiedName())
+	}
+	method, u, body, err := t.transcodeRequest(rule, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for k, vs := range md {
+		for _, v := range vs {
+			httpReq.Header.Add("Grpc-Metadata-"+k, v)
+		}
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, nil, status.Error(codes.Unavailable, err.Error())
+	}
+	defer resp.Body.Close()
+
+	header, trailer := metadata.MD{}, metadata.MD{}
+	for k, vs := range headerMetadata(resp.Header) {
+		switch {
+		case strings.HasPrefix(k, "grpc-metadata-"):
+			header[strings.TrimPrefix(k, "grpc-metadata-")] = vs
+		case strings.HasPrefix(k, "grpc-trailer-"):
+			trailer[strings.TrimPrefix(k, "grpc-trailer-")] = vs
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return header, trailer, t.restStatus(resp.StatusCode, data).Err()
+	}
+
+	if !mdesc.IsServerStreaming() {
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return header, trailer, status.Errorf(codes.Internal, "failed to read HTTP response: %v", err)
+		}
+		msg, err := t.unmarshalResponse(mdesc, rule, data)
+		if err != nil {
+			return header, trailer, err
+		}
+		return header, trailer, onResponse(msg)
+	}
+
+	// streamed responses are newline-delimited {"result": ...} or
+	// {"error": ...} objects
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk struct {
+			Result json.RawMessage `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		if err := dec.Decode(&chunk); err == io.EOF {
+			return header, trailer, nil
+		} else if err != nil {
+			return header, trailer, status.Errorf(codes.Internal, "failed to read HTTP response: %v", err)
+		}
+		if chunk.Error != nil {
+			return header, trailer, t.restStatus(http.StatusInternalServerError, chunk.Error).Err()
+		}
+		msg, err := t.unmarshalResponse(mdesc, rule, chunk.Result)
+		if err != nil {
+			return header, trailer, err
+		}
+		if err := onResponse(msg); err != nil {
+			return header, trailer, err
+		}
+	}
+}
+
+// unmarshalResponse decodes a response, which is only the field named by
+// response_body of rule when it is set.
+func (t *httpTransport) unmarshalResponse(mdesc *desc.MethodDescriptor, rule *annotations.HttpRule, data []byte) (proto.Message, error) {
+	if f := rule.GetResponseBody(); f != "" {
+		b, err := json.Marshal(map[string]json.RawMessage{f: data})
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+	msg := dynamic.NewMessage(mdesc.GetOutputType())
+	if err := msg.UnmarshalJSONPB(t.unmarshaler, data); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to unmarshal response: %v", err)
+	}
+	return msg, nil
+}
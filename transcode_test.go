@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	pb "github.com/kazegusuri/grpcurl/internal/testdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// startHTTPRuleServer starts a mock server whose Echo service is annotated
+// with google.api.http rules.
+func startHTTPRuleServer(t *testing.T) string {
+	fds, err := (&protoparse.Parser{}).ParseFiles("internal/testdata/echo_service.proto")
+	require.NoError(t, err)
+	fdp := fds[0].AsFileDescriptorProto()
+	for _, m := range fdp.GetService()[0].GetMethod() {
+		var rule *annotations.HttpRule
+		switch m.GetName() {
+		case "Echo":
+			rule = &annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Get{Get: "/v1/echo/{value=**}"},
+				AdditionalBindings: []*annotations.HttpRule{{
+					Pattern: &annotations.HttpRule_Post{Post: "/v1/echo"},
+					Body:    "*",
+				}},
+			}
+		case "ServerStreamingEcho":
+			rule = &annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{Post: "/v1/stream"},
+				Body:    "*",
+			}
+		default:
+			continue
+		}
+		m.Options = &dpb.MethodOptions{}
+		require.NoError(t, proto.SetExtension(m.Options, annotations.E_Http, rule))
+	}
+	fd, err := desc.CreateFileDescriptor(fdp)
+	require.NoError(t, err)
+
+	s, err := NewMockServer(NewFileDescriptorSource(fd), nil, &GlobalOptions{Output: &bytes.Buffer{}})
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func TestListServicesHTTPRule(t *testing.T) {
+	ruleAddr := startHTTPRuleServer(t)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "list_services", "-l", ruleAddr, "grpcurl.test.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Contains(t, buf.String(), "grpcurl.test.Echo.Echo(grpcurl.test.EchoMessage) return (grpcurl.test.EchoMessage) [GET /v1/echo/{value=**}, POST /v1/echo body:*]\n")
+	assert.Contains(t, buf.String(), "grpcurl.test.Echo.ClientStreamingEcho(streaming grpcurl.test.EchoMessage) return (grpcurl.test.EchoMessage)\n")
+}
+
+func testCallViaHTTP(t *testing.T, method, msg string, handler http.HandlerFunc) (*bytes.Buffer, error) {
+	ruleAddr := startHTTPRuleServer(t)
+	gw := httptest.NewServer(handler)
+	t.Cleanup(gw.Close)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(msg), buf)
+	cmd.Command().SetArgs([]string{"-k", "call", "-v", "-H", "x-id: 1", "--via-http", gw.URL, ruleAddr, method})
+	return buf, cmd.Command().Execute()
+}
+
+func TestCallViaHTTP(t *testing.T) {
+	var got *http.Request
+	buf, err := testCallViaHTTP(t, "grpcurl.test.Echo.Echo", `{"value": "a/b c", "error_code": 3}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			w.Header().Set("Grpc-Metadata-X-Gateway", "yes")
+			fmt.Fprint(w, `{"value": "from gateway"}`)
+		})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodGet, got.Method)
+	assert.Equal(t, "/v1/echo/a/b%20c", got.URL.EscapedPath())
+	assert.Equal(t, "error_code=3", got.URL.RawQuery)
+	assert.Equal(t, "1", got.Header.Get("Grpc-Metadata-X-Id"))
+
+	resp := parseTestResponse(buf.String())
+	assert.Equal(t, `{"value":"from gateway","error_code":0}`, resp.ResponseMessage)
+	assert.Contains(t, buf.String(), "x-gateway: yes")
+}
+
+func TestCallViaHTTPBody(t *testing.T) {
+	var body string
+	buf, err := testCallViaHTTP(t, "grpcurl.test.Echo.ServerStreamingEcho", `{"value": "hi"}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			b := &bytes.Buffer{}
+			b.ReadFrom(r.Body)
+			body = b.String()
+			fmt.Fprint(w, "{\"result\": {\"value\": \"a\"}}\n{\"result\": {\"value\": \"b\"}}\n")
+		})
+	require.NoError(t, err)
+	assert.Equal(t, `{"value":"hi"}`, body)
+	resp := parseTestResponse(buf.String())
+	assert.Equal(t, "{\"value\":\"a\",\"error_code\":0}\n{\"value\":\"b\",\"error_code\":0}", resp.ResponseMessage)
+}
+
+func TestCallViaHTTPError(t *testing.T) {
+	buf, err := testCallViaHTTP(t, "grpcurl.test.Echo.Echo", `{"value": "x"}`,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code": 5, "message": "no such echo", "details": []}`)
+		})
+	require.NoError(t, err)
+	resp := parseTestResponse(buf.String())
+	assert.Equal(t, `{"code":5,"message":"no such echo","details":[]}`, resp.ResponseMessage)
+}
+
+func TestTranscodeMultiSegmentPath(t *testing.T) {
+	tr := NewHTTPTransport(http.DefaultClient, "http://localhost").(*httpTransport)
+	rule := &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/{value=projects/*/books/*}"},
+	}
+	method, u, body, err := tr.transcodeRequest(rule, &pb.EchoMessage{Value: "projects/p q/books/b?"})
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodGet, method)
+	assert.Equal(t, "http://localhost/v1/projects/p%20q/books/b%3F", u)
+	assert.Nil(t, body)
+}
+
+func TestDescribeHTTPRule(t *testing.T) {
+	ruleAddr := startHTTPRuleServer(t)
+
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "describe", ruleAddr, "grpcurl.test.Echo.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Equal(t, "rpc grpcurl.test.Echo.Echo(grpcurl.test.EchoMessage) returns (grpcurl.test.EchoMessage)\n  http: GET /v1/echo/{value=**}, POST /v1/echo body:*\n", buf.String())
+
+	buf.Reset()
+	cmd = NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "describe", ruleAddr, "grpcurl.test.Echo"})
+	require.NoError(t, cmd.Command().Execute())
+	assert.Contains(t, buf.String(), "service grpcurl.test.Echo\n  rpc Echo(grpcurl.test.EchoMessage) returns (grpcurl.test.EchoMessage)\n    http: GET /v1/echo/{value=**}, POST /v1/echo body:*\n")
+	assert.Contains(t, buf.String(), "  rpc ClientStreamingEcho(stream grpcurl.test.EchoMessage) returns (grpcurl.test.EchoMessage)\n  rpc")
+
+	cmd = NewRootCommand(strings.NewReader(""), buf)
+	cmd.Command().SetArgs([]string{"-k", "describe", ruleAddr, "grpcurl.test.Echo.Unknown"})
+	assert.Error(t, cmd.Command().Execute())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	protocolGRPC    = "grpc"
+	protocolGRPCWeb = "grpc-web"
+	protocolConnect = "connect"
+)
+
+// CallTransport carries unary and server streaming calls to a server.
+type CallTransport interface {
+	// Invoke sends req, with the outgoing metadata of ctx, and passes each
+	// response to onResponse. A call failed by the server is reported as a
+	// status error.
+	Invoke(ctx context.Context, mdesc *desc.MethodDescriptor, req proto.Message, onResponse func(proto.Message) error) (header, trailer metadata.MD, err error)
+}
+
+// grpcTransport is a CallTransport over a gRPC connection.
+type grpcTransport struct {
+	stub grpcdynamic.Stub
+}
+
+func NewGRPCTransport(conn *grpc.ClientConn) CallTransport {
+	return &grpcTransport{stub: grpcdynamic.NewStub(conn)}
+}
+
+func (t *grpcTransport) Invoke(ctx context.Context, mdesc *desc.MethodDescriptor, req proto.Message, onResponse func(proto.Message) error) (metadata.MD, metadata.MD, error) {
+	var header, trailer metadata.MD
+	if !mdesc.IsServerStreaming() {
+		resp, err := t.stub.InvokeRpc(ctx, mdesc, req, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err != nil {
+			return header, trailer, err
+		}
+		return header, trailer, onResponse(resp)
+	}
+	if mdesc.IsClientStreaming() {
+		return nil, nil, fmt.Errorf("client streaming method is not supported")
+	}
+
+	stream, err := t.stub.InvokeRpcServerStream(ctx, mdesc, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		resp, err := stream.RecvMsg()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			header, _ = stream.Header()
+			return header, stream.Trailer(), err
+		}
+		if err := onResponse(resp); err != nil {
+			return nil, nil, err
+		}
+	}
+	header, _ = stream.Header()
+	return header, stream.Trailer(), nil
+}
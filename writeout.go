@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+)
+
+// callTimer measures the phases of a call for --write-out: the dial of the
+// connection as a dialHook, and the call itself as a stats handler. Only the
+// first connection and the first call of the method are measured, so that
+// reconnections and the calls made by server reflection don't count.
+type callTimer struct {
+	mu         sync.Mutex
+	start      time.Time
+	namelookup time.Time
+	connect    time.Time
+	appconnect time.Time
+	resolved   time.Time
+	firstByte  time.Time
+	end        time.Time
+	method     string
+	tagged     bool
+	sizeOut    int
+	sizeIn     int
+	status     codes.Code
+}
+
+func newCallTimer() *callTimer {
+	return &callTimer{start: time.Now()}
+}
+
+type callTimerKey struct{}
+
+// HandleDial implements dialHook.HandleDial, timing the name lookup, the
+// TCP connection and the TLS handshake.
+func (t *callTimer) HandleDial(e *dialEvent) {
+	if e.Err != nil {
+		return
+	}
+	switch e.Phase {
+	case dialLookupDone:
+		t.mark(&t.namelookup)
+	case dialConnectDone:
+		t.mark(&t.connect)
+	case dialHandshakeDone:
+		t.mark(&t.appconnect)
+	}
+}
+
+// mark records the current time in *at, unless it is already set.
+func (t *callTimer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// Resolved records that mdesc was resolved, after which the next call of it
+// is measured.
+func (t *callTimer) Resolved(mdesc *desc.MethodDescriptor) {
+	t.mark(&t.resolved)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.method = "/" + mdesc.GetService().GetFullyQualifiedName() + "/" + mdesc.GetName()
+}
+
+// Done records the status of the call.
+func (t *callTimer) Done(code codes.Code) {
+	t.mark(&t.end)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = code
+}
+
+// TagRPC implements stats.Handler.TagRPC
+func (t *callTimer) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tagged || info.FullMethodName != t.method {
+		return ctx
+	}
+	t.tagged = true
+	return context.WithValue(ctx, callTimerKey{}, true)
+}
+
+// HandleRPC implements stats.Handler.HandleRPC
+func (t *callTimer) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if ctx.Value(callTimerKey{}) == nil {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.OutPayload:
+		t.mu.Lock()
+		t.sizeOut += s.WireLength
+		t.mu.Unlock()
+	case *stats.InHeader:
+		t.mark(&t.firstByte)
+	case *stats.InPayload:
+		t.mark(&t.firstByte)
+		t.mu.Lock()
+		t.sizeIn += s.WireLength
+		t.mu.Unlock()
+	case *stats.InTrailer:
+		t.mark(&t.firstByte)
+	}
+}
+
+// TagConn implements stats.Handler.TagConn
+func (t *callTimer) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+// HandleConn implements stats.Handler.HandleConn
+func (t *callTimer) HandleConn(ctx context.Context, s stats.ConnStats) {
+}
+
+// Variables returns the --write-out variables measured. Times are in seconds
+// since the start of the command, or zero for phases not reached.
+func (t *callTimer) Variables() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	since := func(at time.Time) string {
+		if at.IsZero() {
+			return "0.000000"
+		}
+		return strconv.FormatFloat(at.Sub(t.start).Seconds(), 'f', 6, 64)
+	}
+	return map[string]string{
+		"time_namelookup":    since(t.namelookup),
+		"time_connect":       since(t.connect),
+		"time_appconnect":    since(t.appconnect),
+		"time_reflection":    since(t.resolved),
+		"time_starttransfer": since(t.firstByte),
+		"time_total":         since(t.end),
+		"size_request":       strconv.Itoa(t.sizeOut),
+		"size_response":      strconv.Itoa(t.sizeIn),
+		"status_code":        strconv.Itoa(int(t.status)),
+		"status":             t.status.String(),
+		"method":             t.method,
+	}
+}
+
+var writeOutVariableRegexp = regexp.MustCompile(`%\{([a-z_]+)\}`)
+
+// writeOutVariables are the names of the variables of --write-out formats.
+var writeOutVariables = func() []string {
+	var names []string
+	for name := range newCallTimer().Variables() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}()
+
+// parseWriteOut returns the format of a --write-out value, read from a file
+// if it starts with @, with \n, \t and \\ unescaped. It fails on unknown
+// variables.
+func parseWriteOut(format string) (string, error) {
+	if strings.HasPrefix(format, "@") {
+		b, err := ioutil.ReadFile(format[1:])
+		if err != nil {
+			return "", fmt.Errorf("failed to read write-out format: %v", err)
+		}
+		format = string(b)
+	}
+	format = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`).Replace(format)
+	for _, m := range writeOutVariableRegexp.FindAllStringSubmatch(format, -1) {
+		i := sort.SearchStrings(writeOutVariables, m[1])
+		if i == len(writeOutVariables) || writeOutVariables[i] != m[1] {
+			return "", fmt.Errorf("unknown write-out variable: %s (must be one of %s)", m[1], strings.Join(writeOutVariables, ", "))
+		}
+	}
+	return format, nil
+}
+
+// expandWriteOut replaces the %{name} variables of format with vars.
+func expandWriteOut(format string, vars map[string]string) string {
+	return writeOutVariableRegexp.ReplaceAllStringFunc(format, func(m string) string {
+		return vars[m[2:len(m)-1]]
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWriteOut(t *testing.T, body, format string, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	cmd := NewRootCommand(strings.NewReader(body), buf)
+	cmd.Command().SetArgs(append([]string{"-k", "call", "-w", format, addr, "grpcurl.test.Echo.Echo"}, args...))
+	err := cmd.Command().Execute()
+	return buf.String(), err
+}
+
+func TestWriteOut(t *testing.T) {
+	var format []string
+	for _, name := range writeOutVariables {
+		format = append(format, name+"=%{"+name+"}")
+	}
+	out, err := testWriteOut(t, `{"value": "hi"}`, strings.Join(format, `\t`))
+	require.NoError(t, err)
+
+	lines := strings.Split(out, "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"value":"hi","error_code":0}`, lines[0])
+	vars := map[string]string{}
+	for _, kv := range strings.Split(lines[1], "\t") {
+		parts := strings.SplitN(kv, "=", 2)
+		vars[parts[0]] = parts[1]
+	}
+	assert.Equal(t, "OK", vars["status"])
+	assert.Equal(t, "0", vars["status_code"])
+	assert.Equal(t, "/grpcurl.test.Echo/Echo", vars["method"])
+	// the message of 4 bytes after the 5 bytes of the gRPC message header
+	assert.Equal(t, "9", vars["size_request"])
+	assert.Equal(t, "9", vars["size_response"])
+	assert.Equal(t, "0.000000", vars["time_appconnect"])
+
+	var last float64
+	for _, name := range []string{"time_namelookup", "time_connect", "time_reflection", "time_starttransfer", "time_total"} {
+		v, err := strconv.ParseFloat(vars[name], 64)
+		require.NoError(t, err, name)
+		assert.True(t, v > 0 && v >= last, "%s: %v", name, v)
+		last = v
+	}
+}
+
+func TestWriteOutStatus(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "format")
+	require.NoError(t, ioutil.WriteFile(file, []byte("%{status_code} %{status}"), 0644))
+	out, err := testWriteOut(t, `{"value": "hi", "error_code": 5}`, "@"+file)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(out, "\n5 NotFound"), out)
+}
+
+func TestWriteOutErrors(t *testing.T) {
+	_, err := testWriteOut(t, `{}`, "%{time_unknown}")
+	assert.EqualError(t, err, "unknown write-out variable: time_unknown (must be one of "+strings.Join(writeOutVariables, ", ")+")")
+
+	_, err = testWriteOut(t, `{}`, "%{time_total}", "--protocol", protocolGRPCWeb)
+	assert.EqualError(t, err, "--write-out can only be used with --protocol grpc")
+}